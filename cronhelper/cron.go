@@ -0,0 +1,285 @@
+package cronhelper
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression, see Parse
+type Schedule struct {
+	second, minute, hour, dom, month, dow uint64 // bit sets of the allowed values
+	domStar, dowStar, hourStar            bool   // day of month, day of week or hour is unrestricted
+	every                                 time.Duration
+	loc                                   *time.Location
+}
+
+// field describes the range of a cron field and the names allowed in it
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = field{min: 0, max: 59}
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Day of week 7 is Sunday as well and is folded into 0
+	dowField = field{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// macros are the @ shortcuts and their expressions
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// maxSearchYears limits the search of Next for expressions which never match, e.g. "0 0 30 2 *"
+const maxSearchYears = 5
+
+// Parse parses a cron expression in the local time zone, see ParseInLocation
+func Parse(expr string) (*Schedule, error) {
+	return ParseInLocation(expr, time.Local)
+}
+
+// ParseInLocation parses a cron expression, whose times are interpreted in loc. Supported are:
+//
+//   - the standard 5 fields "minute hour day-of-month month day-of-week",
+//   - 6 fields with a leading seconds field,
+//   - in every field: *, values, ranges (1-5), steps (*/15, 10-50/5) and lists (1,15,30),
+//     month and weekday names (jan-dec, sun-sat) and ? as alias of *,
+//   - the macros @yearly (@annually), @monthly, @weekly, @daily (@midnight) and @hourly,
+//   - @every <duration> (e.g. @every 90s) for fixed intervals,
+//   - a prefix CRON_TZ=<zone> or TZ=<zone> overriding loc, e.g. "CRON_TZ=Europe/Vienna 0 6 * * *".
+//
+// If both day of month and day of week are restricted, a day matching either one matches, as in cron.
+func ParseInLocation(expr string, loc *time.Location) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if loc == nil {
+		loc = time.Local
+	}
+
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		zone, rest, _ := strings.Cut(expr, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("invalid time zone in cron expression %q: %w", expr, err)
+		}
+		expr = strings.TrimSpace(rest)
+	}
+
+	if strings.HasPrefix(expr, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		if every < time.Second {
+			return nil, fmt.Errorf("invalid cron expression %q: interval below one second", expr)
+		}
+		return &Schedule{every: every, loc: loc}, nil
+	}
+	if macro, ok := macros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 or 6 fields, got %d", expr, len(fields))
+	}
+
+	s := &Schedule{loc: loc}
+	targets := []*uint64{&s.second, &s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	for i, f := range []field{secondField, minuteField, hourField, domField, monthField, dowField} {
+		bits, err := parseField(fields[i], f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		*targets[i] = bits
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = isStar(fields[3])
+	s.dowStar = isStar(fields[5])
+	s.hourStar = isStar(fields[2])
+
+	return s, nil
+}
+
+// MustParse works like Parse, but panics if the expression is invalid
+func MustParse(expr string) *Schedule {
+	s, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Location returns the time zone of the schedule
+func (s *Schedule) Location() *time.Location {
+	return s.loc
+}
+
+// Next returns the first time after t matching the schedule, in the location of the schedule.
+// It returns the zero time if there is none within the next years (e.g. for February 30th).
+//
+// Daylight saving time is handled like in cron. When the clocks are put forward, times in the
+// skipped hour run at the first instant after it, so "30 2 * * *" runs at 03:00 on that day.
+// When the clocks are put back, the wall clock times of one hour repeat. Unless the hour field is
+// unrestricted, a repeated time is only returned once: Next skips the times whose wall clock is not
+// later than the one of t.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.In(s.loc).Truncate(time.Second).Add(s.every)
+	}
+
+	from := wallClock(t.In(s.loc))
+	t = t.In(s.loc).Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		var next time.Time
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		case !s.dayMatches(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			// Step in absolute time, so hours skipped or repeated by daylight saving time are handled
+			next = t.Add(time.Duration(60-t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Truncate(time.Minute).Add(time.Minute)
+		case s.second&(1<<uint(t.Second())) == 0:
+			next = t.Add(time.Second)
+		case !s.hourStar && !wallClock(t).After(from):
+			// A repeated wall clock time at the end of daylight saving time, which has passed already
+			next = t.Add(time.Second)
+		default:
+			return t
+		}
+
+		// If the step crossed wall clock times skipped at the start of daylight saving time,
+		// a match among them runs at the first instant after the gap
+		skippedFrom := wallClock(t).Add(next.Sub(t))
+		if skippedTo := wallClock(next); skippedTo.After(skippedFrom) && s.matchesWallClock(skippedFrom, skippedTo) {
+			return next
+		}
+		t = next
+	}
+
+	return time.Time{}
+}
+
+// matchesWallClock reports whether the schedule matches a wall clock minute between from (inclusive)
+// and to (exclusive), given as UTC times by wallClock
+func (s *Schedule) matchesWallClock(from, to time.Time) bool {
+	for w := from.Truncate(time.Minute); w.Before(to); w = w.Add(time.Minute) {
+		if s.month&(1<<uint(w.Month())) != 0 && s.dayMatches(w) &&
+			s.hour&(1<<uint(w.Hour())) != 0 && s.minute&(1<<uint(w.Minute())) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// wallClock returns the local date and time of t as UTC time, to compare wall clock times
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// dayMatches applies the day of month and day of week fields to the day of t
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// isStar reports whether a field is unrestricted
+func isStar(expr string) bool {
+	return expr == "*" || expr == "?"
+}
+
+// parseField parses a comma separated list of a cron field into a bit set
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepExpr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case isStar(rangeExpr):
+		case strings.Contains(rangeExpr, "-"):
+			lowExpr, highExpr, _ := strings.Cut(rangeExpr, "-")
+			var err error
+			if low, err = parseValue(lowExpr, f); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(highExpr, f); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			var err error
+			if low, err = parseValue(rangeExpr, f); err != nil {
+				return 0, err
+			}
+			high = low
+			if hasStep {
+				// "5/15" means every 15 starting at 5
+				high = f.max
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a number or name of a cron field and checks its range
+func parseValue(expr string, f field) (int, error) {
+	if value, ok := f.names[strings.ToLower(expr)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(expr)
+	if err != nil {
+		return 0, errors.New("invalid value " + strconv.Quote(expr))
+	}
+	if value < f.min || value > f.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", value, f.min, f.max)
+	}
+	return value, nil
+}
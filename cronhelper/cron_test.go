@@ -0,0 +1,80 @@
+package cronhelper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// the table itself
+		{"POS every minute", "* * * * *", time.Date(2024, 6, 30, 12, 0, 30, 0, vienna), time.Date(2024, 6, 30, 12, 1, 0, 0, vienna)},
+		{"POS daily macro", "@daily", time.Date(2024, 6, 30, 12, 0, 0, 0, vienna), time.Date(2024, 7, 1, 0, 0, 0, 0, vienna)},
+		{"POS seconds field", "*/15 * * * * *", time.Date(2024, 6, 30, 12, 0, 16, 0, vienna), time.Date(2024, 6, 30, 12, 0, 30, 0, vienna)},
+		{"POS weekday names", "0 6 * * mon-fri", time.Date(2024, 6, 29, 12, 0, 0, 0, vienna), time.Date(2024, 7, 1, 6, 0, 0, 0, vienna)},
+		{"POS sunday as 7", "0 0 * * 7", time.Date(2024, 6, 27, 0, 0, 0, 0, vienna), time.Date(2024, 6, 30, 0, 0, 0, 0, vienna)},
+		{"POS day of month or week", "0 0 13 * fri", time.Date(2024, 9, 1, 0, 0, 0, 0, vienna), time.Date(2024, 9, 6, 0, 0, 0, 0, vienna)},
+		{"POS last day of february", "0 0 29 2 *", time.Date(2024, 3, 1, 0, 0, 0, 0, vienna), time.Date(2028, 2, 29, 0, 0, 0, 0, vienna)},
+		{"POS skipped hour", "30 2 * * *", time.Date(2024, 3, 31, 0, 0, 0, 0, vienna), time.Date(2024, 3, 31, 3, 0, 0, 0, vienna)},
+		{"POS skipped hour next day", "30 2 * * *", time.Date(2024, 3, 31, 3, 0, 0, 0, vienna), time.Date(2024, 4, 1, 2, 30, 0, 0, vienna)},
+		{"POS skipped hour by minutes", "30 1,2 * * *", time.Date(2024, 3, 31, 1, 30, 0, 0, vienna), time.Date(2024, 3, 31, 3, 0, 0, 0, vienna)},
+		{"POS hour after gap", "15 3 * * *", time.Date(2024, 3, 31, 0, 0, 0, 0, vienna), time.Date(2024, 3, 31, 3, 15, 0, 0, vienna)},
+		{"POS hourly over gap", "0 * * * *", time.Date(2024, 3, 31, 1, 0, 0, 0, vienna), time.Date(2024, 3, 31, 3, 0, 0, 0, vienna)},
+		{"POS repeated hour once", "30 2 * * *", time.Date(2024, 10, 27, 2, 30, 0, 0, vienna), time.Date(2024, 10, 28, 2, 30, 0, 0, vienna)},
+		{"POS repeated hour first", "30 2 * * *", time.Date(2024, 10, 27, 0, 0, 0, 0, vienna), time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC)},
+		{"POS repeated hour hourly", "30 * * * *", time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC), time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC)},
+		{"POS time zone prefix", "CRON_TZ=UTC 0 12 * * *", time.Date(2024, 6, 30, 13, 0, 0, 0, vienna), time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)},
+		{"POS every", "@every 90s", time.Date(2024, 6, 30, 12, 0, 0, 0, vienna), time.Date(2024, 6, 30, 12, 1, 30, 0, vienna)},
+		{"NEG never", "0 0 30 2 *", time.Date(2024, 1, 1, 0, 0, 0, 0, vienna), time.Time{}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseInLocation(tt.expr, vienna)
+			if err != nil {
+				t.Fatalf("parse %s: %v", tt.expr, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name string
+		expr string
+	}{
+		// the table itself
+		{"NEG too few fields", "* * * *"},
+		{"NEG out of range", "60 * * * *"},
+		{"NEG inverted range", "0 10-5 * * *"},
+		{"NEG zero step", "*/0 * * * *"},
+		{"NEG unknown name", "0 0 * foo *"},
+		{"NEG unknown time zone", "CRON_TZ=Nowhere/City 0 0 * * *"},
+		{"NEG every below one second", "@every 500ms"},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.expr); err == nil {
+				t.Errorf("expected an error for %q", tt.expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package cronhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/smithyat/go-helpers/filehelper"
+	"github.com/smithyat/go-helpers/logger"
+	"io"
+	"sync"
+	"time"
+)
+
+// JobFunc is the work of a scheduled job. The context is cancelled when the scheduler stops.
+type JobFunc func(ctx context.Context) error
+
+// SchedulerOptions controls a Scheduler. The zero value keeps the last runs in memory only.
+type SchedulerOptions struct {
+	// StatePath is the file the last successful run of every job is stored in (see
+	// filehelper.ScheduleState), so it survives restarts. Empty disables persistence.
+	StatePath string
+
+	// CatchUp runs a job once at the start if a scheduled time was missed since its last
+	// successful run, e.g. while the daemon was down. Requires StatePath.
+	CatchUp bool
+}
+
+// Scheduler runs jobs at the times of their cron expressions within the process. A job never runs
+// concurrently with itself: if it is still running at its next time, that run is skipped.
+//
+// Example:
+//
+//	scheduler, err := NewScheduler(SchedulerOptions{StatePath: "/var/lib/mydaemon/cron.json"}, logger.Log)
+//	err = scheduler.Add("export", "CRON_TZ=Europe/Vienna 0 6 * * mon-fri", export)
+//	scheduler.Run(ctx) // blocks until ctx is done
+type Scheduler struct {
+	opts  SchedulerOptions
+	log   *logrus.Entry
+	state *filehelper.ScheduleState
+
+	mu      sync.Mutex
+	jobs    []*job
+	started bool
+}
+
+type job struct {
+	name     string
+	schedule *Schedule
+	fn       JobFunc
+
+	mu      sync.Mutex
+	running bool
+	lastRun time.Time
+}
+
+// NewScheduler returns an empty scheduler. Messages are logged to logPtr, or to logger.Log if it is nil.
+func NewScheduler(opts SchedulerOptions, logPtr *logrus.Entry) (*Scheduler, error) {
+	if logPtr == nil {
+		logPtr = logger.Log
+	}
+	if logPtr == nil {
+		logPtr = logrus.NewEntry(logrus.New())
+		logPtr.Logger.SetOutput(io.Discard)
+	}
+
+	s := &Scheduler{opts: opts, log: logPtr}
+	if opts.StatePath != "" {
+		state, err := filehelper.OpenScheduleState(opts.StatePath, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.state = state
+	}
+	return s, nil
+}
+
+// Add registers fn under the unique name to run at the times of the cron expression (see
+// ParseInLocation, in the local time zone unless the expression sets one). Jobs have to be added
+// before Run is called.
+func (s *Scheduler) Add(name, expr string, fn JobFunc) error {
+	schedule, err := Parse(expr)
+	if err != nil {
+		return err
+	}
+	return s.AddSchedule(name, schedule, fn)
+}
+
+// AddSchedule works like Add with a parsed schedule
+func (s *Scheduler) AddSchedule(name string, schedule *Schedule, fn JobFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return errors.New("scheduler already running")
+	}
+	for _, j := range s.jobs {
+		if j.name == name {
+			return fmt.Errorf("job %s already registered", name)
+		}
+	}
+
+	j := &job{name: name, schedule: schedule, fn: fn}
+	if s.state != nil {
+		j.lastRun, _ = s.state.LastRun(name)
+	}
+	s.jobs = append(s.jobs, j)
+	return nil
+}
+
+// Run starts the jobs and blocks until ctx is done and all running jobs have returned
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.started = true
+	jobs := s.jobs
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			s.loop(ctx, j, &wg)
+		}(j)
+	}
+	wg.Wait()
+}
+
+// loop waits for the times of a job and starts its runs
+func (s *Scheduler) loop(ctx context.Context, j *job, wg *sync.WaitGroup) {
+	now := time.Now()
+	if s.opts.CatchUp && !j.lastRun.IsZero() {
+		if missed := j.schedule.Next(j.lastRun); !missed.IsZero() && missed.Before(now) {
+			s.log.Infof("job %s missed its run at %s, catching up", j.name, missed.Format(time.RFC3339))
+			s.start(ctx, j, wg)
+		}
+	}
+
+	for {
+		next := j.schedule.Next(now)
+		if next.IsZero() {
+			s.log.Warnf("job %s has no further scheduled time [%s]", j.name, logger.Trace())
+			return
+		}
+		s.log.Debugf("job %s next run at %s", j.name, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.start(ctx, j, wg)
+		now = time.Now()
+	}
+}
+
+// start runs the job in its own goroutine, unless its previous run is still active
+func (s *Scheduler) start(ctx context.Context, j *job, wg *sync.WaitGroup) {
+	j.mu.Lock()
+	if j.running {
+		j.mu.Unlock()
+		s.log.Warnf("job %s is still running, skipping this run [%s]", j.name, logger.Trace())
+		return
+	}
+	j.running = true
+	j.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			j.mu.Lock()
+			j.running = false
+			j.mu.Unlock()
+		}()
+		s.execute(ctx, j)
+	}()
+}
+
+// execute calls the job function, logs the outcome and records a successful run
+func (s *Scheduler) execute(ctx context.Context, j *job) {
+	started := time.Now()
+	s.log.Infof("job %s started", j.name)
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return j.fn(ctx)
+	}()
+	if err != nil {
+		s.log.Errorf("job %s failed after %s: %v [%s]", j.name, time.Since(started).Round(time.Millisecond), err, logger.Trace())
+		return
+	}
+	s.log.Infof("job %s finished after %s", j.name, time.Since(started).Round(time.Millisecond))
+
+	j.mu.Lock()
+	j.lastRun = started
+	j.mu.Unlock()
+	if s.state != nil {
+		if err := s.state.Commit(j.name, started); err != nil {
+			s.log.Errorf("failed to store last run of job %s: %v [%s]", j.name, err, logger.Trace())
+		}
+	}
+}
+
+// LastRun returns the start time of the last successful run of the job, false if it never succeeded
+func (s *Scheduler) LastRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.name == name {
+			j.mu.Lock()
+			defer j.mu.Unlock()
+			return j.lastRun, !j.lastRun.IsZero()
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,132 @@
+package cronhelper
+
+import (
+	"bytes"
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/smithyat/go-helpers/filehelper"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testLog returns a logger writing into a buffer, to check the messages after Run returned
+func testLog() (*logrus.Entry, *bytes.Buffer) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	return logrus.NewEntry(log), &buf
+}
+
+func TestSchedulerSkipsOverlap(t *testing.T) {
+	logPtr, buf := testLog()
+	scheduler, err := NewScheduler(SchedulerOptions{}, logPtr)
+	if err != nil {
+		t.Fatalf("NewScheduler() error = %v", err)
+	}
+
+	var runs int32
+	err = scheduler.Add("slow", "@every 1s", func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		<-ctx.Done()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	scheduler.Run(ctx)
+
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Errorf("runs = %d, want 1", got)
+	}
+	if !strings.Contains(buf.String(), "job slow is still running, skipping this run") {
+		t.Errorf("skipped run not logged:\n%s", buf.String())
+	}
+}
+
+func TestSchedulerPersistsLastRun(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "cron.json")
+	logPtr, _ := testLog()
+
+	first, err := NewScheduler(SchedulerOptions{StatePath: statePath}, logPtr)
+	if err != nil {
+		t.Fatalf("NewScheduler() error = %v", err)
+	}
+	if err := first.Add("job", "@every 1s", func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	first.Run(ctx)
+
+	lastRun, ok := first.LastRun("job")
+	if !ok {
+		t.Fatal("LastRun() = false after a run")
+	}
+
+	second, err := NewScheduler(SchedulerOptions{StatePath: statePath}, logPtr)
+	if err != nil {
+		t.Fatalf("NewScheduler() error = %v", err)
+	}
+	if err := second.Add("job", "@every 1s", func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	got, ok := second.LastRun("job")
+	if !ok || !got.Equal(lastRun) {
+		t.Errorf("LastRun() after restart = %v, %v, want %v", got, ok, lastRun)
+	}
+}
+
+func TestSchedulerCatchUp(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		catchUp bool
+		want    int32
+	}{
+		// the table itself
+		{"POS missed run caught up once", true, 1},
+		{"NEG catch up disabled", false, 0},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statePath := filepath.Join(t.TempDir(), "cron.json")
+			state, err := filehelper.OpenScheduleState(statePath, nil)
+			if err != nil {
+				t.Fatalf("OpenScheduleState() error = %v", err)
+			}
+			if err := state.Commit("job", time.Now().Add(-2*time.Hour)); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+
+			logPtr, _ := testLog()
+			scheduler, err := NewScheduler(SchedulerOptions{StatePath: statePath, CatchUp: tt.catchUp}, logPtr)
+			if err != nil {
+				t.Fatalf("NewScheduler() error = %v", err)
+			}
+			var runs int32
+			err = scheduler.Add("job", "@every 1h", func(ctx context.Context) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			scheduler.Run(ctx)
+
+			if got := atomic.LoadInt32(&runs); got != tt.want {
+				t.Errorf("runs = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
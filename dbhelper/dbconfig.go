@@ -1,5 +1,7 @@
 package dbhelper
 
+import "time"
+
 // DbConfig is the configuration for the database
 type DbConfig struct {
 	Username string
@@ -7,4 +9,10 @@ type DbConfig struct {
 	Host     string
 	Port     string
 	Database string
+
+	// StatementTimeout is the default maximum runtime of a single statement.
+	// It is applied server-side when the connection is opened (statement_timeout on
+	// Postgres, max_execution_time on MySQL) and client-side by QueryContext.
+	// Zero disables the timeout.
+	StatementTimeout time.Duration
 }
@@ -0,0 +1,105 @@
+package dbtest
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// RowCount returns the number of rows in table matching where, which is a map of column names
+// to expected values combined with AND. A nil value matches NULL. An empty where counts all rows.
+func RowCount(q sqlx.Queryer, table string, where map[string]interface{}) (int, error) {
+	clause, args := whereClause(where)
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", table, clause)
+
+	var count int
+	if err := sqlx.Get(q, &count, sqlx.Rebind(sqlx.BindType(driverName(q)), query), args...); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// AssertRowCount fails the test if table does not contain exactly want rows matching where.
+// See RowCount for the format of where.
+func AssertRowCount(t testing.TB, q sqlx.Queryer, table string, where map[string]interface{}, want int) {
+	t.Helper()
+
+	got, err := RowCount(q, table, where)
+	if err != nil {
+		t.Fatalf("dbtest: failed to count rows of %s: %v", table, err)
+	}
+	if got != want {
+		t.Errorf("dbtest: table %s has %d rows matching %v, want %d", table, got, where, want)
+	}
+}
+
+// AssertRowExists fails the test if table contains no row matching where.
+// See RowCount for the format of where.
+func AssertRowExists(t testing.TB, q sqlx.Queryer, table string, where map[string]interface{}) {
+	t.Helper()
+
+	got, err := RowCount(q, table, where)
+	if err != nil {
+		t.Fatalf("dbtest: failed to count rows of %s: %v", table, err)
+	}
+	if got == 0 {
+		t.Errorf("dbtest: table %s has no row matching %v", table, where)
+	}
+}
+
+// AssertNoRow fails the test if table contains a row matching where.
+// See RowCount for the format of where.
+func AssertNoRow(t testing.TB, q sqlx.Queryer, table string, where map[string]interface{}) {
+	t.Helper()
+
+	got, err := RowCount(q, table, where)
+	if err != nil {
+		t.Fatalf("dbtest: failed to count rows of %s: %v", table, err)
+	}
+	if got != 0 {
+		t.Errorf("dbtest: table %s has %d rows matching %v, want none", table, got, where)
+	}
+}
+
+// AssertTableEmpty fails the test if table contains any row
+func AssertTableEmpty(t testing.TB, q sqlx.Queryer, table string) {
+	t.Helper()
+	AssertNoRow(t, q, table, nil)
+}
+
+// whereClause builds a " WHERE ..." clause with '?' placeholders from the map,
+// in alphabetical column order so the generated SQL is stable.
+func whereClause(where map[string]interface{}) (string, []interface{}) {
+	if len(where) == 0 {
+		return "", nil
+	}
+
+	columns := make([]string, 0, len(where))
+	for column := range where {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	conditions := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		if where[column] == nil {
+			conditions = append(conditions, column+" IS NULL")
+			continue
+		}
+		conditions = append(conditions, column+" = ?")
+		args = append(args, where[column])
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// driverName returns the driver name of q if it exposes one (*sqlx.DB and *sqlx.Tx do)
+func driverName(q sqlx.Queryer) string {
+	if d, ok := q.(interface{ DriverName() string }); ok {
+		return d.DriverName()
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+// Package dbtest provides helpers for integration tests of code built on dbhelper.
+//
+// A test opens the test database once with Open, wraps its work in a transaction with Tx,
+// loads its data with LoadFixtures and checks the outcome with the Assert helpers.
+// Because the transaction is rolled back when the test finishes, no data is left behind
+// and tests do not see each other's rows.
+//
+// Usage:
+//
+//	func TestOrderRepository(t *testing.T) {
+//	    db := dbtest.Open(t)
+//	    tx := dbtest.Tx(t, db)
+//	    dbtest.LoadFixtures(t, tx, "testdata/orders.yaml")
+//
+//	    err := NewOrderRepository(tx).Close(42)
+//	    if err != nil {
+//	        t.Fatal(err)
+//	    }
+//
+//	    dbtest.AssertRowExists(t, tx, "orders", map[string]interface{}{"id": 42, "state": "closed"})
+//	}
+package dbtest
+
+import (
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"testing"
+)
+
+const (
+	// EnvDriver is the environment variable holding the database/sql driver name of the test database,
+	// e.g. "pgx" or "mysql". It is required if EnvDSN is set.
+	EnvDriver = "DBTEST_DRIVER"
+	// EnvDSN is the environment variable holding the DSN of the test database
+	EnvDSN = "DBTEST_DSN"
+)
+
+// sqliteDrivers are the names the common SQLite drivers register themselves with
+var sqliteDrivers = []string{"sqlite3", "sqlite"}
+
+// Open opens the database used by the test and closes it again when the test and all its
+// subtests have finished.
+//
+// If EnvDSN is set, the database described by EnvDriver and EnvDSN is used. Otherwise, an
+// in-memory SQLite database is opened. No SQLite driver is linked by this package, so the test
+// binary has to import one (e.g. _ "github.com/mattn/go-sqlite3" or the pure Go _ "modernc.org/sqlite",
+// which the tests of this package use).
+// If neither a DSN nor a SQLite driver is available, the test is skipped, so integration tests
+// do not fail on machines without a database.
+//
+// The in-memory SQLite database is limited to a single connection, because every connection
+// would otherwise see its own empty database.
+func Open(t testing.TB) *sqlx.DB {
+	t.Helper()
+
+	driver, dsn := os.Getenv(EnvDriver), os.Getenv(EnvDSN)
+	memory := false
+	if dsn == "" {
+		driver = registeredSqliteDriver()
+		if driver == "" {
+			t.Skipf("dbtest: %s is not set and no SQLite driver is registered", EnvDSN)
+		}
+		dsn, memory = ":memory:", true
+	} else if driver == "" {
+		t.Fatalf("dbtest: %s is set but %s is empty", EnvDSN, EnvDriver)
+	}
+
+	db, err := sqlx.Connect(driver, dsn)
+	if err != nil {
+		t.Fatalf("dbtest: failed to connect to test database (%s): %v", driver, err)
+	}
+	if memory {
+		db.SetMaxOpenConns(1)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+// Tx begins a transaction on db and rolls it back when the test and all its subtests have finished.
+// Every statement of the test has to use the returned transaction to be isolated.
+//
+// Note that some databases (e.g. MySQL) implicitly commit DDL statements such as CREATE TABLE,
+// so schema changes should be made before calling Tx.
+func Tx(t testing.TB, db *sqlx.DB) *sqlx.Tx {
+	t.Helper()
+
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatalf("dbtest: failed to begin transaction: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			t.Errorf("dbtest: failed to roll back transaction: %v", err)
+		}
+	})
+
+	return tx
+}
+
+// registeredSqliteDriver returns the name of the first registered SQLite driver or
+// an empty string if there is none.
+func registeredSqliteDriver() string {
+	registered := make(map[string]bool)
+	for _, name := range sql.Drivers() {
+		registered[name] = true
+	}
+	for _, name := range sqliteDrivers {
+		if registered[name] {
+			return name
+		}
+	}
+	return ""
+}
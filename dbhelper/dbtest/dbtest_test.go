@@ -0,0 +1,116 @@
+package dbtest
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"path/filepath"
+	"testing"
+
+	// Pure Go SQLite driver, so the tests run without an external database
+	_ "modernc.org/sqlite"
+)
+
+// recordingTB records failures instead of failing the test, to check the Assert helpers fail
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+// openOrders opens the test database with an orders table
+func openOrders(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db := Open(t)
+	if _, err := db.Exec("CREATE TABLE orders (id INTEGER PRIMARY KEY, state TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = db.Exec("DROP TABLE orders")
+	})
+	return db
+}
+
+func TestTxRollsBack(t *testing.T) {
+	db := openOrders(t)
+
+	t.Run("insert", func(t *testing.T) {
+		tx := Tx(t, db)
+		if _, err := tx.Exec("INSERT INTO orders (id, state) VALUES (42, 'open')"); err != nil {
+			t.Fatal(err)
+		}
+		AssertRowCount(t, tx, "orders", nil, 1)
+	})
+
+	// The transaction of the subtest has been rolled back when it finished
+	AssertTableEmpty(t, db, "orders")
+
+	t.Run("isolated", func(t *testing.T) {
+		tx := Tx(t, db)
+		AssertTableEmpty(t, tx, "orders")
+	})
+}
+
+func TestLoadFixtures(t *testing.T) {
+	db := openOrders(t)
+	tx := Tx(t, db)
+
+	path := filepath.Join(t.TempDir(), "orders.yaml")
+	if err := os.WriteFile(path, []byte("orders:\n  - id: 42\n    state: open\n  - id: 43\n    state: null\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	LoadFixtures(t, tx, path)
+
+	AssertRowCount(t, tx, "orders", nil, 2)
+	AssertRowExists(t, tx, "orders", map[string]interface{}{"id": 42, "state": "open"})
+	AssertRowExists(t, tx, "orders", map[string]interface{}{"id": 43, "state": nil})
+}
+
+func TestAssertions(t *testing.T) {
+	db := openOrders(t)
+	tx := Tx(t, db)
+	if _, err := tx.Exec("INSERT INTO orders (id, state) VALUES (42, 'open'), (43, 'closed'), (44, NULL)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name     string
+		assert   func(tb testing.TB)
+		wantFail bool
+	}{
+		// the table itself
+		{"POS row count", func(tb testing.TB) { AssertRowCount(tb, tx, "orders", nil, 3) }, false},
+		{"POS row count with where", func(tb testing.TB) {
+			AssertRowCount(tb, tx, "orders", map[string]interface{}{"state": "open"}, 1)
+		}, false},
+		{"POS row exists", func(tb testing.TB) { AssertRowExists(tb, tx, "orders", map[string]interface{}{"id": 43}) }, false},
+		{"POS row with null exists", func(tb testing.TB) { AssertRowExists(tb, tx, "orders", map[string]interface{}{"state": nil}) }, false},
+		{"POS no row", func(tb testing.TB) { AssertNoRow(tb, tx, "orders", map[string]interface{}{"state": "cancelled"}) }, false},
+		{"NEG row count", func(tb testing.TB) { AssertRowCount(tb, tx, "orders", nil, 2) }, true},
+		{"NEG row exists", func(tb testing.TB) { AssertRowExists(tb, tx, "orders", map[string]interface{}{"id": 99}) }, true},
+		{"NEG no row", func(tb testing.TB) { AssertNoRow(tb, tx, "orders", map[string]interface{}{"id": 42}) }, true},
+		{"NEG table empty", func(tb testing.TB) { AssertTableEmpty(tb, tx, "orders") }, true},
+		{"NEG unknown table", func(tb testing.TB) { AssertRowCount(tb, tx, "missing", nil, 0) }, true},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &recordingTB{TB: t}
+			tt.assert(recorder)
+			if failed := len(recorder.failures) > 0; failed != tt.wantFail {
+				t.Errorf("failed = %v %v, want %v", failed, recorder.failures, tt.wantFail)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package dbtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// CSVNull is the field value that is loaded as NULL from CSV fixtures
+const CSVNull = `\N`
+
+// Fixture holds the rows of a single table
+type Fixture struct {
+	Table string
+	Rows  []map[string]interface{}
+}
+
+// LoadFixtures reads the given fixture files and inserts their rows using q, which is usually
+// the transaction returned by Tx. Files are loaded in the given order and the test fails on
+// the first error.
+//
+// The format is chosen by the file extension:
+//
+// YAML (.yaml, .yml) files map table names to lists of rows. Tables are loaded in the order
+// in which they appear in the file, so referenced tables should come first:
+//
+//	customers:
+//	  - id: 1
+//	    name: ACME
+//	orders:
+//	  - id: 42
+//	    customer_id: 1
+//	    state: open
+//
+// CSV (.csv) files hold the rows of a single table named like the file without its extension
+// (orders.csv loads into "orders"). The first record contains the column names, every field
+// is inserted as a string, and the value \N is inserted as NULL.
+func LoadFixtures(t testing.TB, q sqlx.Ext, paths ...string) {
+	t.Helper()
+
+	for _, path := range paths {
+		fixtures, err := ReadFixtureFile(path)
+		if err != nil {
+			t.Fatalf("dbtest: %v", err)
+		}
+		for _, fixture := range fixtures {
+			if err := InsertFixture(q, fixture); err != nil {
+				t.Fatalf("dbtest: failed to load %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// ReadFixtureFile parses a YAML or CSV fixture file as described in LoadFixtures
+func ReadFixtureFile(path string) ([]Fixture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var fixtures []Fixture
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		fixtures, err = readYamlFixtures(file)
+	case ".csv":
+		table := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		var fixture Fixture
+		fixture, err = readCsvFixture(file, table)
+		fixtures = []Fixture{fixture}
+	default:
+		return nil, fmt.Errorf("unsupported fixture file %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fixture file %s: %v", path, err)
+	}
+
+	return fixtures, nil
+}
+
+// InsertFixture inserts all rows of the fixture into its table using q.
+// Columns are inserted in alphabetical order, and the placeholders are rebound
+// for the driver of q.
+func InsertFixture(q sqlx.Ext, fixture Fixture) error {
+	for _, row := range fixture.Rows {
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			args[i] = row[column]
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			fixture.Table, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+		if _, err := q.Exec(q.Rebind(query), args...); err != nil {
+			return fmt.Errorf("insert into %s: %v", fixture.Table, err)
+		}
+	}
+	return nil
+}
+
+// readYamlFixtures decodes a YAML mapping of table names to rows, keeping the order of the tables
+func readYamlFixtures(r io.Reader) ([]Fixture, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping of table names to rows", root.Line)
+	}
+
+	var fixtures []Fixture
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		fixture := Fixture{Table: root.Content[i].Value}
+		if err := root.Content[i+1].Decode(&fixture.Rows); err != nil {
+			return nil, fmt.Errorf("table %s: %v", fixture.Table, err)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+
+	return fixtures, nil
+}
+
+// readCsvFixture reads the rows of a single table from CSV data with a header record
+func readCsvFixture(r io.Reader, table string) (Fixture, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return Fixture{}, err
+	}
+
+	fixture := Fixture{Table: table}
+	if len(records) == 0 {
+		return fixture, nil
+	}
+
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if record[i] == CSVNull {
+				row[column] = nil
+			} else {
+				row[column] = record[i]
+			}
+		}
+		fixture.Rows = append(fixture.Rows, row)
+	}
+
+	return fixture, nil
+}
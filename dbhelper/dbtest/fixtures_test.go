@@ -0,0 +1,53 @@
+package dbtest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadFixtureFile(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		file    string
+		content string
+		want    []Fixture
+		wantErr bool
+	}{
+		// the table itself
+		{"POS yaml keeps table order", "fixtures.yaml", "orders:\n  - id: 42\n    state: open\ncustomers:\n  - id: 1\n    name: ACME\n",
+			[]Fixture{
+				{Table: "orders", Rows: []map[string]interface{}{{"id": 42, "state": "open"}}},
+				{Table: "customers", Rows: []map[string]interface{}{{"id": 1, "name": "ACME"}}},
+			}, false},
+		{"POS yaml null", "fixtures.yml", "customers:\n  - id: 1\n    name: null\n",
+			[]Fixture{{Table: "customers", Rows: []map[string]interface{}{{"id": 1, "name": nil}}}}, false},
+		{"POS yaml empty", "empty.yaml", "", nil, false},
+		{"POS csv with null", "orders.csv", "id,state\n42,open\n43,\\N\n",
+			[]Fixture{{Table: "orders", Rows: []map[string]interface{}{{"id": "42", "state": "open"}, {"id": "43", "state": nil}}}}, false},
+		{"POS csv header only", "orders.csv", "id,state\n", []Fixture{{Table: "orders"}}, false},
+		{"NEG yaml list at top level", "fixtures.yaml", "- id: 1\n", nil, true},
+		{"NEG yaml rows not a list", "fixtures.yaml", "customers: ACME\n", nil, true},
+		{"NEG yaml syntax", "fixtures.yaml", "customers: [\n", nil, true},
+		{"NEG csv field count", "orders.csv", "id,state\n42\n", nil, true},
+		{"NEG unsupported extension", "orders.json", "{}", nil, true},
+	}
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadFixtureFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
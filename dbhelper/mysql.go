@@ -11,6 +11,7 @@ import (
 // The DSN string is created using the DbConfig object, only it masks the password for security reasons.
 // The returned DSN string includes the username, masked password, host, port, and database name.
 // Two additional parameters are added: a connection timeout of 5 seconds and automatic commit mode enabled.
+// If a StatementTimeout is configured, it is added as max_execution_time as well.
 // This function is especially useful when logging or displaying the DSN without exposing sensitive information.
 //
 // The function accepts a single parameter:
@@ -19,8 +20,7 @@ import (
 // Returns:
 // - dsn: a string representing the masked DSN of the MySQL database connection.
 func MySqlLogDSN(dbConfig DbConfig) (dsn string) {
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?timeout=5s&autocommit=true",
-		dbConfig.Username, "******", dbConfig.Host, dbConfig.Port, dbConfig.Database)
+	return mySqlDSN(dbConfig, "******")
 }
 
 // MySQLConnect is a function that allows the application to establish a connection with the MySQL database.
@@ -29,6 +29,9 @@ func MySqlLogDSN(dbConfig DbConfig) (dsn string) {
 // It utilizes the sqlx library's Connect function to create a connection with the MySQL database. If the connection is established successfully,
 // it sets the maximum connection lifetime, maximum number of open connections and maximum idle connections for the MySQL database.
 //
+// If the configuration carries a StatementTimeout, every session sets max_execution_time accordingly,
+// so the server aborts SELECT statements running longer than that.
+//
 // If a connection is not established, the function returns an error. Otherwise, it returns a pointer to the DB and a function to close connection when done.
 //
 // Usage:
@@ -52,8 +55,7 @@ func MySqlLogDSN(dbConfig DbConfig) (dsn string) {
 //
 // error: If there is any error encountered during the process, it will be returned as an error type
 func MySQLConnect(dbConfig DbConfig) (db *sqlx.DB, disconnect func(), dbErr error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?timeout=5s&autocommit=true",
-		dbConfig.Username, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+	dsn := mySqlDSN(dbConfig, dbConfig.Password)
 
 	mysqlDB, err := sqlx.Connect("mysql", dsn)
 	if err != nil {
@@ -68,3 +70,15 @@ func MySQLConnect(dbConfig DbConfig) (db *sqlx.DB, disconnect func(), dbErr erro
 		_ = mysqlDB.Close()
 	}, nil
 }
+
+// mySqlDSN builds the MySQL DSN for the given configuration using password as the password part.
+// Unknown DSN parameters are sent by the driver as system variables, which is used here to set
+// max_execution_time (in milliseconds) for every new session.
+func mySqlDSN(dbConfig DbConfig, password string) string {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?timeout=5s&autocommit=true",
+		dbConfig.Username, password, dbConfig.Host, dbConfig.Port, dbConfig.Database)
+	if dbConfig.StatementTimeout > 0 {
+		dsn += fmt.Sprintf("&max_execution_time=%d", timeoutMillis(dbConfig.StatementTimeout))
+	}
+	return dsn
+}
@@ -0,0 +1,97 @@
+package dbhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"time"
+)
+
+// ErrQueryTimeout is returned (wrapped) by QueryError when a statement was aborted
+// because it ran longer than its deadline, either on the client or on the server.
+// Use errors.Is(err, ErrQueryTimeout) to check for it.
+var ErrQueryTimeout = errors.New("query timeout")
+
+const (
+	// pgQueryCanceled is the SQLSTATE Postgres reports when statement_timeout is hit
+	pgQueryCanceled = "57014"
+	// mysqlQueryTimeout is the error number MySQL reports when max_execution_time is hit
+	mysqlQueryTimeout = 3024
+)
+
+// QueryContext derives a context from parent which is bounded by the StatementTimeout
+// of the configuration. If StatementTimeout is zero, the returned context is only
+// cancelable and carries no deadline of its own.
+//
+// The returned cancel function must always be called to release the context resources.
+//
+// Usage:
+//
+//	ctx, cancel := cfg.QueryContext(context.Background())
+//	defer cancel()
+//	err := db.GetContext(ctx, &count, "SELECT count(*) FROM orders")
+//	if errors.Is(dbhelper.QueryError(err), dbhelper.ErrQueryTimeout) {
+//	    // handle the runaway query
+//	}
+func (dbConfig DbConfig) QueryContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return WithQueryTimeout(parent, dbConfig.StatementTimeout)
+}
+
+// WithQueryTimeout derives a context from parent which expires after timeout.
+// A timeout of zero or less returns a context without a deadline of its own,
+// so a single call can opt out of the configured default.
+func WithQueryTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// QueryError inspects an error returned by a database call and translates every kind of
+// statement timeout into an error matching ErrQueryTimeout. This covers an expired
+// client-side context as well as the server-side timeout errors of Postgres and MySQL.
+// The original error is kept in the chain, so errors.Is and errors.As still work on it.
+// Any other error, including nil, is returned unchanged.
+func QueryError(err error) error {
+	if err == nil || errors.Is(err, ErrQueryTimeout) {
+		return err
+	}
+	if isQueryTimeout(err) {
+		return fmt.Errorf("%w: %w", ErrQueryTimeout, err)
+	}
+	return err
+}
+
+// isQueryTimeout reports whether err is caused by a statement exceeding its deadline
+func isQueryTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgQueryCanceled {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlQueryTimeout {
+		return true
+	}
+
+	return false
+}
+
+// timeoutMillis converts the timeout into the whole milliseconds expected by the servers.
+// Timeouts below one millisecond are rounded up, so they are not mistaken for "disabled".
+func timeoutMillis(timeout time.Duration) int64 {
+	ms := timeout.Milliseconds()
+	if ms == 0 && timeout > 0 {
+		ms = 1
+	}
+	return ms
+}
@@ -0,0 +1,49 @@
+package dbhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"testing"
+	"time"
+)
+
+func TestQueryError(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input error
+		want  bool
+	}{
+		{"POS deadline", context.DeadlineExceeded, true},
+		{"POS wrapped deadline", fmt.Errorf("select: %w", context.DeadlineExceeded), true},
+		{"POS postgres", &pgconn.PgError{Code: "57014"}, true},
+		{"POS mysql", &mysql.MySQLError{Number: 3024}, true},
+		{"NEG canceled", context.Canceled, false},
+		{"NEG postgres", &pgconn.PgError{Code: "23505"}, false},
+		{"NEG mysql", &mysql.MySQLError{Number: 1062}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := QueryError(tt.input)
+			if got := errors.Is(err, ErrQueryTimeout); got != tt.want {
+				t.Errorf("got %v, want %v : %v", got, tt.want, err)
+			}
+			if !errors.Is(err, tt.input) {
+				t.Errorf("original error lost : %v", err)
+			}
+		})
+	}
+}
+
+func TestTimeoutDSN(t *testing.T) {
+	cfg := DbConfig{Username: "u", Password: "p", Host: "h", Port: "1", Database: "d", StatementTimeout: 1500 * time.Millisecond}
+
+	if got, want := PgLogDSN(cfg), "postgres://u:*****@h:1/d?statement_timeout=1500"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := MySqlLogDSN(cfg), "u:******@tcp(h:1)/d?timeout=5s&autocommit=true&max_execution_time=1500"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package filehelper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveOptions controls how an archive is created from a directory tree.
+// The zero value archives every file and keeps the sources.
+type ArchiveOptions struct {
+	RemoveSources  bool      // remove the archived files once the archive is completely written and verified
+	Trash          *Trash    // with RemoveSources, move the archived files into this trash instead of deleting them; it is never archived
+	Include        []string  // glob patterns, only files matching at least one are archived; empty archives all
+	Exclude        []string  // glob patterns of files and directories to leave out
+	FollowSymlinks bool      // archive the content of symlinked files instead of the link, symlinked directories stay links
+	Reproducible   bool      // normalize headers (owner, access times), so equal trees produce equal archives
+	ModTime        time.Time // with Reproducible, use this modification time for all entries instead of the file's one
+
+	// Manifest adds a checksum manifest of all archived files as last entry, named after the algorithm
+	// (e.g. SHA256SUMS), if set. A source file of that name at the root of the archive is an error.
+	// See VerifyArchiveManifest.
+	Manifest HashAlgorithm
+
+	// Gzip holds the compression settings of tar.gz archives, e.g. to compress on several goroutines
+	Gzip GzipOptions
+
+	// ZipMethod selects the compression method (zip.Store, zip.Deflate or a registered one) per ZIP entry,
+	// based on the path on disk. If it is nil, all entries are deflated. Ignored for tar archives.
+	ZipMethod func(path string, info os.FileInfo) uint16
+}
+
+// archiveFile is a single file system entry selected for an archive
+type archiveFile struct {
+	path     string      // path on disk
+	name     string      // slash-separated name inside the archive, directories end with '/'
+	info     os.FileInfo // result of Lstat, or of Stat for followed symlinks
+	linkname string      // target of a symlink
+}
+
+// collectArchiveFiles walks sourcePath in lexical order and returns the entries to archive.
+// Names are relative to relPath, or to sourcePath if relPath is empty. Glob patterns are
+// matched like in ListFiles. The file at skipPath (the archive being written) and the trash of opts
+// are left out.
+func collectArchiveFiles(sourcePath, relPath, skipPath string, opts ArchiveOptions) ([]archiveFile, error) {
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	base := relPath
+	if base == "" {
+		base = sourcePath
+	}
+
+	skipAbs, _ := filepath.Abs(skipPath)
+
+	var files []archiveFile
+	err := filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if abs, _ := filepath.Abs(path); abs == skipAbs {
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, "../") || name == ".." {
+			return fmt.Errorf("path %s is outside of the relative path %s", path, relPath)
+		}
+
+		srcRel, _ := filepath.Rel(sourcePath, path)
+		if matchAnyGlob(opts.Exclude, info.Name(), filepath.ToSlash(srcRel)) || inTrash(path, opts.Trash) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			files = append(files, archiveFile{path: path, name: name + "/", info: info})
+			return nil
+		}
+
+		if len(opts.Include) > 0 && !matchAnyGlob(opts.Include, info.Name(), filepath.ToSlash(srcRel)) {
+			return nil
+		}
+
+		file := archiveFile{path: path, name: name, info: info}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if opts.FollowSymlinks && err == nil && target.Mode().IsRegular() {
+				file.info = target
+			} else if file.linkname, err = os.Readlink(path); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() {
+			// sockets, devices and fifos cannot be archived sensibly
+			return nil
+		}
+
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// archiveEntryCount returns the number of entries written for the files, including the manifest
+func archiveEntryCount(files []archiveFile, opts ArchiveOptions) int {
+	if opts.Manifest != "" {
+		return len(files) + 1
+	}
+	return len(files)
+}
+
+// removeArchivedFiles removes the regular files and symlinks of an archive, keeping the directories.
+// If trash is not nil, the files are moved into it. All files are tried, the errors are joined.
+func removeArchivedFiles(files []archiveFile, trash *Trash) error {
+	var errs []error
+	for _, file := range files {
+		if file.info.IsDir() {
+			continue
+		}
+		if err := removeOrTrash(file.path, trash); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
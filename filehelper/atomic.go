@@ -0,0 +1,175 @@
+package filehelper
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AtomicWriter is an io.WriteCloser which writes to a temporary file in the directory of the
+// target path and only replaces the target on a successful Close. Readers of the target path
+// therefore either see the old file or the complete new one, never partial output, even if the
+// process crashes while writing.
+//
+// Close syncs the temporary file to disk, renames it to the target path and syncs the directory,
+// so the new file survives a power loss once Close has returned. If anything fails, or if Abort
+// is called instead of Close, the temporary file is removed and the target stays untouched.
+//
+// Usage:
+//
+//	w, err := NewAtomicWriter("/data/out/report.csv", 0644)
+//	if err != nil {
+//	    return err
+//	}
+//	defer w.Abort()
+//	if _, err := io.Copy(w, src); err != nil {
+//	    return err
+//	}
+//	return w.Close()
+type AtomicWriter struct {
+	path string
+	perm os.FileMode
+	file *os.File
+	done bool
+
+	exactPerm bool // apply perm as it is, e.g. the mode of a copied file
+}
+
+// NewAtomicWriter creates the temporary file for path. Like os.WriteFile, a new target gets the
+// permissions perm minus the umask of the process, and an existing target keeps its permissions.
+func NewAtomicWriter(path string, perm os.FileMode) (*AtomicWriter, error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	file, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &AtomicWriter{path: path, perm: perm, file: file}, nil
+}
+
+// Name returns the target path
+func (w *AtomicWriter) Name() string {
+	return w.path
+}
+
+// Write writes to the temporary file
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, os.ErrClosed
+	}
+	return w.file.Write(p)
+}
+
+// Close commits the written data: it syncs and closes the temporary file, renames it to the
+// target path and syncs the directory. Calling Close after a commit or an abort does nothing.
+func (w *AtomicWriter) Close() error {
+	return w.closeWith(nil, func(tmp string) error {
+		return os.Rename(tmp, w.path)
+	})
+}
+
+// closeWith works like Close, but calls prepare on the synced temporary file before it becomes
+// visible, e.g. to set its metadata, and moves it into place with publish. If publish leaves the
+// temporary file where it is, it is removed.
+func (w *AtomicWriter) closeWith(prepare func(tmp string) error, publish func(tmp string) error) error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+
+	tmp := w.file.Name()
+	err := w.file.Sync()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, w.mode())
+	}
+	if err == nil && prepare != nil {
+		err = prepare(tmp)
+	}
+	if err == nil {
+		err = publish(tmp)
+	}
+	_ = os.Remove(tmp)
+	if err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(w.path))
+}
+
+// mode returns the permissions of the new file: those of the file it replaces, or perm minus the umask
+func (w *AtomicWriter) mode() os.FileMode {
+	if w.exactPerm {
+		return w.perm
+	}
+	if info, err := os.Stat(w.path); err == nil && info.Mode().IsRegular() {
+		return info.Mode().Perm()
+	}
+	return w.perm &^ umask
+}
+
+// Abort discards the written data and removes the temporary file. It is safe to call
+// Abort after Close, e.g. in a defer statement, in which case it does nothing.
+func (w *AtomicWriter) Abort() {
+	if w.done {
+		return
+	}
+	w.done = true
+	_ = w.file.Close()
+	_ = os.Remove(w.file.Name())
+}
+
+// WriteFileAtomic writes data to the file at path like os.WriteFile, but through an AtomicWriter,
+// so the file at path is replaced completely or not at all. Permissions are set like os.WriteFile
+// does (see NewAtomicWriter).
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	w, err := NewAtomicWriter(path, perm)
+	if err != nil {
+		return err
+	}
+	defer w.Abort()
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// isAtomicTemp reports whether name is the name of a temporary file of an AtomicWriter
+func isAtomicTemp(name string) bool {
+	i := strings.LastIndex(name, ".tmp-")
+	if !strings.HasPrefix(name, ".") || i < 1 || i+len(".tmp-") == len(name) {
+		return false
+	}
+	for _, c := range name[i+len(".tmp-"):] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// syncDir flushes the directory entry changes of dir to disk. File systems which do not
+// support syncing directories are ignored.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func(d *os.File) {
+		_ = d.Close()
+	}(d)
+
+	if err := d.Sync(); err != nil && !errors.Is(err, unix.EINVAL) && !errors.Is(err, unix.ENOTSUP) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package filehelper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicWriter(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name   string
+		finish func(w *AtomicWriter) error // called after writing "new"
+		want   string                      // content of the target afterwards
+	}{
+		// the table itself
+		{"POS close", func(w *AtomicWriter) error { return w.Close() }, "new"},
+		{"POS close twice", func(w *AtomicWriter) error {
+			if err := w.Close(); err != nil {
+				return err
+			}
+			return w.Close()
+		}, "new"},
+		{"POS abort after close", func(w *AtomicWriter) error {
+			err := w.Close()
+			w.Abort()
+			return err
+		}, "new"},
+		{"NEG abort", func(w *AtomicWriter) error {
+			w.Abort()
+			return nil
+		}, "old"},
+		{"NEG close after abort", func(w *AtomicWriter) error {
+			w.Abort()
+			return w.Close()
+		}, "old"},
+		{"NEG failed write", func(w *AtomicWriter) error {
+			_ = w.file.Close()
+			if _, err := w.Write([]byte("more")); err == nil {
+				t.Error("got no write error")
+			}
+			w.Abort()
+			return nil
+		}, "old"},
+		{"NEG failed close", func(w *AtomicWriter) error {
+			_ = w.file.Close()
+			if err := w.Close(); err == nil {
+				t.Error("got no close error")
+			}
+			return nil
+		}, "old"},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "report.csv")
+			if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := NewAtomicWriter(target, 0640)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte("new")); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.finish(w); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(target)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("got %q : %v, want %q", got, err, tt.want)
+			}
+			// No temporary file may be left behind
+			entries, err := os.ReadDir(dir)
+			if err != nil || len(entries) != 1 {
+				t.Errorf("got %d directory entries : %v, want 1", len(entries), err)
+			}
+			if tt.want == "new" {
+				// The replaced file keeps its permissions
+				if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0644 {
+					t.Errorf("got permissions %v : %v, want 0644", info.Mode().Perm(), err)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "new.csv")
+	if err := WriteFileAtomic(target, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(target); err != nil || string(got) != "data" {
+		t.Errorf("got %q : %v", got, err)
+	}
+
+	// A missing directory fails before anything is written
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "new.csv"), []byte("data"), 0600); err == nil {
+		t.Error("got no error for a missing directory")
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("got %d directory entries : %v, want 1", len(entries), err)
+	}
+}
+
+func TestAtomicWriterPermissions(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name     string
+		umask    os.FileMode
+		existing os.FileMode // mode of the existing target, 0 if there is none
+		perm     os.FileMode
+		want     os.FileMode
+	}{
+		// the table itself
+		{"POS new file", 022, 0, 0644, 0644},
+		{"POS new file restrictive umask", 077, 0, 0644, 0600},
+		{"POS new file group umask", 027, 0, 0666, 0640},
+		{"POS existing file keeps mode", 022, 0600, 0644, 0600},
+		{"POS existing file ignores umask", 077, 0644, 0600, 0644},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withUmask(t, tt.umask)
+			target := filepath.Join(t.TempDir(), "report.csv")
+			if tt.existing != 0 {
+				if err := os.WriteFile(target, []byte("old"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(target, tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := WriteFileAtomic(target, []byte("new"), tt.perm); err != nil {
+				t.Fatal(err)
+			}
+			if info, err := os.Stat(target); err != nil || info.Mode().Perm() != tt.want {
+				t.Errorf("got permissions %v : %v, want %v", info.Mode().Perm(), err, tt.want)
+			}
+		})
+	}
+}
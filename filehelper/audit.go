@@ -0,0 +1,203 @@
+package filehelper
+
+import (
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"time"
+)
+
+// FileExpectation describes files that have to be present in an audited directory
+type FileExpectation struct {
+	Name    string // label of the expectation in the report, Pattern if empty
+	Pattern string // glob pattern of the file names (or relative paths, if it contains a '/')
+
+	// Min and Max bound the number of matching files, 0 means no bound. With PerDay, they apply to
+	// every day of the window, e.g. Min 1 and Max 1 for exactly one file per day.
+	Min, Max int
+
+	// PerDay counts the matching files per day, dated by the date in their name or else their
+	// modification time. The window is the last Days days (1 if 0) up to today, or up to yesterday
+	// with ExcludeToday, e.g. if today's file is not due yet.
+	PerDay       bool
+	Days         int
+	ExcludeToday bool
+
+	MaxAge  time.Duration // matching files modified longer ago are stale, 0 disables the check
+	MinSize int64         // matching files smaller than this are reported as empty, e.g. 1 for zero-byte files
+}
+
+// AuditOptions controls AuditDir
+type AuditOptions struct {
+	Allow     []string          // glob patterns of legitimate files which are not covered by an expectation
+	Expect    []FileExpectation // files that have to be present
+	Recursive bool              // audit subdirectories as well
+	Now       time.Time         // reference time of ages and days, time.Now() if zero
+	Location  *time.Location    // time zone of the days, time.Local if nil
+}
+
+// CountViolation is a file count outside the bounds of an expectation
+type CountViolation struct {
+	Expectation string
+	Day         time.Time // the day counted, zero if the expectation is not per day
+	Found       int
+	Min, Max    int
+}
+
+// AuditFinding is a file violating an expectation
+type AuditFinding struct {
+	Expectation string
+	File        FileInfo
+}
+
+// AuditReport is the result of AuditDir
+type AuditReport struct {
+	Unexpected []FileInfo       // files matching neither an allow pattern nor an expectation
+	Missing    []CountViolation // fewer files than the expectation's Min
+	Excess     []CountViolation // more files than the expectation's Max
+	Stale      []AuditFinding   // files older than the expectation's MaxAge
+	Empty      []AuditFinding   // files smaller than the expectation's MinSize
+}
+
+// OK reports whether the audit found no problem
+func (r AuditReport) OK() bool {
+	return len(r.Unexpected) == 0 && len(r.Missing) == 0 && len(r.Excess) == 0 && len(r.Stale) == 0 && len(r.Empty) == 0
+}
+
+// Log writes one warning per finding to logPtr
+func (r AuditReport) Log(logPtr *logrus.Entry) {
+	for _, file := range r.Unexpected {
+		logPtr.Warnf("unknown file detected %s", file.Path)
+	}
+	for _, v := range r.Missing {
+		logPtr.Warnf("missing files for %s%s: found %d, expected at least %d", v.Expectation, auditDay(v.Day), v.Found, v.Min)
+	}
+	for _, v := range r.Excess {
+		logPtr.Warnf("too many files for %s%s: found %d, expected at most %d", v.Expectation, auditDay(v.Day), v.Found, v.Max)
+	}
+	for _, f := range r.Stale {
+		logPtr.Warnf("stale file %s for %s, modified %s", f.File.Path, f.Expectation, f.File.ModTime.Format(time.RFC3339))
+	}
+	for _, f := range r.Empty {
+		logPtr.Warnf("empty file %s for %s, %d bytes", f.File.Path, f.Expectation, f.File.Size)
+	}
+}
+
+// AuditDir checks the files of dir against the expectations and allow patterns of opts and reports
+// unexpected, missing, excess, stale and empty files. Unlike LogFilesInDir, legitimate files are not
+// reported. If logPtr is not nil, the findings are logged as warnings (see AuditReport.Log).
+// A malformed Allow or Expect pattern is returned as filepath.ErrBadPattern before listing dir.
+//
+// Example, exactly one export per day for the last week and nothing else besides a README:
+//
+//	report, err := AuditDir(dir, AuditOptions{
+//		Allow:  []string{"README*"},
+//		Expect: []FileExpectation{{Pattern: "export_*.csv", PerDay: true, Days: 7, Min: 1, Max: 1, MinSize: 1}},
+//	}, logPtr)
+func AuditDir(dir string, opts AuditOptions, logPtr *logrus.Entry) (AuditReport, error) {
+	var report AuditReport
+
+	patterns := append([]string{}, opts.Allow...)
+	for _, expect := range opts.Expect {
+		patterns = append(patterns, expect.Pattern)
+	}
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return report, err
+		}
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	listOpts := ListOptions{}
+	if opts.Recursive {
+		listOpts.MaxDepth = UnlimitedDepth
+	}
+	files, err := ListFiles(dir, listOpts)
+	if err != nil {
+		return report, err
+	}
+
+	matched := make([]bool, len(files))
+	for _, expect := range opts.Expect {
+		name := expect.Name
+		if name == "" {
+			name = expect.Pattern
+		}
+
+		var matching []FileInfo
+		for i, file := range files {
+			if !matchAnyGlob([]string{expect.Pattern}, file.Name, filepath.ToSlash(file.RelPath)) {
+				continue
+			}
+			matched[i] = true
+			matching = append(matching, file)
+
+			if expect.MaxAge > 0 && now.Sub(file.ModTime) > expect.MaxAge {
+				report.Stale = append(report.Stale, AuditFinding{Expectation: name, File: file})
+			}
+			if file.Size < expect.MinSize {
+				report.Empty = append(report.Empty, AuditFinding{Expectation: name, File: file})
+			}
+		}
+
+		if !expect.PerDay {
+			report.checkCount(name, time.Time{}, len(matching), expect)
+			continue
+		}
+
+		perDay := make(map[string]int)
+		for _, file := range matching {
+			date, _ := fileDate(file, DateFromNameOrModTime, loc)
+			perDay[PeriodDay.Key(date)]++
+		}
+		days := expect.Days
+		if days <= 0 {
+			days = 1
+		}
+		last := PeriodDay.Start(now.In(loc))
+		if expect.ExcludeToday {
+			last = last.AddDate(0, 0, -1)
+		}
+		for i := days - 1; i >= 0; i-- {
+			day := last.AddDate(0, 0, -i)
+			report.checkCount(name, day, perDay[PeriodDay.Key(day)], expect)
+		}
+	}
+
+	for i, file := range files {
+		if !matched[i] && !matchAnyGlob(opts.Allow, file.Name, filepath.ToSlash(file.RelPath)) {
+			report.Unexpected = append(report.Unexpected, file)
+		}
+	}
+
+	if logPtr != nil {
+		report.Log(logPtr)
+	}
+	return report, nil
+}
+
+// checkCount records a count outside the bounds of the expectation
+func (r *AuditReport) checkCount(name string, day time.Time, found int, expect FileExpectation) {
+	violation := CountViolation{Expectation: name, Day: day, Found: found, Min: expect.Min, Max: expect.Max}
+	if expect.Min > 0 && found < expect.Min {
+		r.Missing = append(r.Missing, violation)
+	}
+	if expect.Max > 0 && found > expect.Max {
+		r.Excess = append(r.Excess, violation)
+	}
+}
+
+// auditDay formats the day of a count violation for the log
+func auditDay(day time.Time) string {
+	if day.IsZero() {
+		return ""
+	}
+	return " on " + day.Format("2006-01-02")
+}
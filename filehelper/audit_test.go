@@ -0,0 +1,145 @@
+package filehelper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// auditSummary reduces a report to file names and days, to compare it in tests
+type auditSummary struct {
+	Unexpected, Missing, Excess, Stale, Empty []string
+}
+
+func summarizeAudit(report AuditReport) auditSummary {
+	var summary auditSummary
+	for _, file := range report.Unexpected {
+		summary.Unexpected = append(summary.Unexpected, file.Name)
+	}
+	for _, v := range report.Missing {
+		summary.Missing = append(summary.Missing, v.Expectation+auditDay(v.Day))
+	}
+	for _, v := range report.Excess {
+		summary.Excess = append(summary.Excess, v.Expectation+auditDay(v.Day))
+	}
+	for _, f := range report.Stale {
+		summary.Stale = append(summary.Stale, f.File.Name)
+	}
+	for _, f := range report.Empty {
+		summary.Empty = append(summary.Empty, f.File.Name)
+	}
+	return summary
+}
+
+func TestAuditDir(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		files   map[string]string
+		old     []string // files modified two days before now, the others one hour before
+		allow   []string
+		expect  FileExpectation
+		want    auditSummary
+		wantOK  bool
+		wantErr bool
+	}{
+		// the table itself
+		{
+			name:   "POS expected and allowed files",
+			files:  map[string]string{"export_20240630.csv": "x", "README.md": "x"},
+			allow:  []string{"README*"},
+			expect: FileExpectation{Name: "export", Pattern: "export_*.csv", PerDay: true, Min: 1, Max: 1},
+			wantOK: true,
+		},
+		{
+			name:   "NEG unexpected file",
+			files:  map[string]string{"export_20240630.csv": "x", "README.md": "x", "dump.tmp": "x"},
+			allow:  []string{"README*"},
+			expect: FileExpectation{Name: "export", Pattern: "export_*.csv", PerDay: true, Min: 1, Max: 1},
+			want:   auditSummary{Unexpected: []string{"dump.tmp"}},
+		},
+		{
+			name:   "NEG missing days excluding today",
+			files:  map[string]string{"export_20240628.csv": "x", "export_20240630.csv": "x"},
+			expect: FileExpectation{Name: "export", Pattern: "export_*.csv", PerDay: true, Min: 1, Max: 1, Days: 3, ExcludeToday: true},
+			want:   auditSummary{Missing: []string{"export on 2024-06-27", "export on 2024-06-29"}},
+		},
+		{
+			name:   "NEG too many files per day",
+			files:  map[string]string{"export_20240629.csv": "x", "export_20240629_retry.csv": "x", "export_20240630.csv": "x"},
+			expect: FileExpectation{Name: "export", Pattern: "export_*.csv", PerDay: true, Min: 1, Max: 1, Days: 2},
+			want:   auditSummary{Excess: []string{"export on 2024-06-29"}},
+		},
+		{
+			name:   "NEG stale file",
+			files:  map[string]string{"status.json": "{}", "export_20240630.csv": "x"},
+			old:    []string{"status.json"},
+			allow:  []string{"export_*"},
+			expect: FileExpectation{Pattern: "status.json", Min: 1, MaxAge: 24 * time.Hour},
+			want:   auditSummary{Stale: []string{"status.json"}},
+		},
+		{
+			name:   "NEG empty file",
+			files:  map[string]string{"export_20240630.csv": ""},
+			expect: FileExpectation{Name: "export", Pattern: "export_*.csv", PerDay: true, Min: 1, Max: 1, MinSize: 1},
+			want:   auditSummary{Empty: []string{"export_20240630.csv"}},
+		},
+		{
+			name:   "NEG no file at all",
+			files:  map[string]string{},
+			expect: FileExpectation{Pattern: "status.json", Min: 1},
+			want:   auditSummary{Missing: []string{"status.json"}},
+		},
+		{
+			name:    "NEG malformed expect pattern",
+			files:   map[string]string{"export_20240630.csv": "x"},
+			expect:  FileExpectation{Pattern: "export_[.csv", Min: 1},
+			wantErr: true,
+		},
+		{
+			name:    "NEG malformed allow pattern",
+			files:   map[string]string{"export_20240630.csv": "x", "README.md": "x"},
+			allow:   []string{"README[*"},
+			expect:  FileExpectation{Pattern: "export_*.csv", Min: 1},
+			wantErr: true,
+		},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestTree(t, dir, tt.files)
+			for name := range tt.files {
+				modTime := now.Add(-time.Hour)
+				for _, old := range tt.old {
+					if old == name {
+						modTime = now.Add(-48 * time.Hour)
+					}
+				}
+				if err := os.Chtimes(filepath.Join(dir, name), modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := AuditOptions{Allow: tt.allow, Expect: []FileExpectation{tt.expect}, Now: now, Location: time.UTC}
+			report, err := AuditDir(dir, opts, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AuditDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := summarizeAudit(report); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AuditDir() = %+v, want %+v", got, tt.want)
+			}
+			if report.OK() != tt.wantOK {
+				t.Errorf("AuditDir() OK = %v, want %v", report.OK(), tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package filehelper
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HashAlgorithm is a checksum algorithm supported by the checksum functions
+type HashAlgorithm string
+
+const (
+	MD5    HashAlgorithm = "md5"
+	SHA1   HashAlgorithm = "sha1"
+	SHA256 HashAlgorithm = "sha256"
+)
+
+// ErrChecksumMismatch is returned when a file does not match its recorded checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// New returns a new hash.Hash for the algorithm
+func (a HashAlgorithm) New() (hash.Hash, error) {
+	switch a {
+	case MD5:
+		return md5.New(), nil
+	case SHA1:
+		return sha1.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %q", string(a))
+	}
+}
+
+// Extension returns the extension of sidecar checksum files, e.g. ".sha256"
+func (a HashAlgorithm) Extension() string {
+	return "." + string(a)
+}
+
+// ManifestName returns the conventional name of a manifest file, e.g. "SHA256SUMS"
+func (a HashAlgorithm) ManifestName() string {
+	return strings.ToUpper(string(a)) + "SUMS"
+}
+
+// ReaderChecksum reads r to the end and returns the hex encoded checksum of the data
+func ReaderChecksum(r io.Reader, algo HashAlgorithm) (string, error) {
+	h, err := algo.New()
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FileChecksum returns the hex encoded checksum of the file at path. The file is streamed,
+// so files of any size can be hashed with constant memory.
+func FileChecksum(path string, algo HashAlgorithm) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	return ReaderChecksum(f, algo)
+}
+
+// WriteChecksumFile computes the checksum of the file at path and writes it to a sidecar file
+// next to it, named after the file plus the extension of the algorithm (data.csv.sha256).
+// The sidecar uses the sha256sum format "<checksum>  <file name>", so it can also be checked
+// with the coreutils tools. The sidecar is written atomically. It returns the path of the sidecar file.
+func WriteChecksumFile(path string, algo HashAlgorithm) (string, error) {
+	sum, err := FileChecksum(path, algo)
+	if err != nil {
+		return "", err
+	}
+
+	sidecar := path + algo.Extension()
+	if err := WriteFileAtomic(sidecar, []byte(manifestLine(sum, filepath.Base(path))), 0644); err != nil {
+		return "", err
+	}
+	return sidecar, nil
+}
+
+// VerifyChecksumFile compares the file at path with the checksum stored in its sidecar file
+// (see WriteChecksumFile). It returns an error wrapping ErrChecksumMismatch if they differ.
+func VerifyChecksumFile(path string, algo HashAlgorithm) error {
+	content, err := os.ReadFile(path + algo.Extension())
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(strings.TrimPrefix(string(content), "\\"))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file %s", path+algo.Extension())
+	}
+
+	sum, err := FileChecksum(path, algo)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, fields[0]) {
+		return fmt.Errorf("%s: %w", path, ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// ManifestReport is the result of verifying files against a checksum manifest
+type ManifestReport struct {
+	Verified   []string // files matching their checksum
+	Missing    []string // files listed in the manifest but not present
+	Extra      []string // files present but not listed in the manifest
+	Mismatched []string // files whose checksum differs from the manifest
+}
+
+// OK reports whether all listed files are present and unchanged and no extra files exist
+func (r ManifestReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// WriteManifest computes the checksums of all files below dir and writes them to manifestPath in
+// the SHA256SUMS format: one "<checksum>  <path>" line per file, with slash-separated paths relative
+// to dir, sorted by path. Paths containing backslashes or line breaks are escaped like sha256sum
+// does. If the manifest is located inside dir, it is not listed itself. The manifest is written
+// atomically, so a concurrent verifier never reads a partial manifest.
+func WriteManifest(dir, manifestPath string, algo HashAlgorithm) error {
+	if _, err := algo.New(); err != nil {
+		return err
+	}
+
+	files, err := ListFiles(dir, ListOptions{MaxDepth: UnlimitedDepth, Sort: SortByPath})
+	if err != nil {
+		return err
+	}
+
+	manifestAbs, _ := filepath.Abs(manifestPath)
+	var buf bytes.Buffer
+	for _, file := range files {
+		if abs, _ := filepath.Abs(file.Path); abs == manifestAbs {
+			continue
+		}
+		sum, err := FileChecksum(file.Path, algo)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(manifestLine(sum, filepath.ToSlash(file.RelPath)))
+	}
+
+	return WriteFileAtomic(manifestPath, buf.Bytes(), 0644)
+}
+
+// VerifyManifest checks the files below dir against the manifest at manifestPath written by
+// WriteManifest (or by sha256sum and friends) and returns a report of verified, missing, extra
+// and mismatching files. The manifest itself is never reported as extra file.
+// An error is only returned if the manifest or the directory cannot be read.
+func VerifyManifest(dir, manifestPath string, algo HashAlgorithm) (ManifestReport, error) {
+	var report ManifestReport
+
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return report, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	expected, err := readManifest(f)
+	if err != nil {
+		return report, err
+	}
+
+	files, err := ListFiles(dir, ListOptions{MaxDepth: UnlimitedDepth, Sort: SortByPath})
+	if err != nil {
+		return report, err
+	}
+
+	manifestAbs, _ := filepath.Abs(manifestPath)
+	actual := make(map[string]string)
+	for _, file := range files {
+		if abs, _ := filepath.Abs(file.Path); abs == manifestAbs {
+			continue
+		}
+		actual[filepath.ToSlash(file.RelPath)] = file.Path
+	}
+
+	return compareManifest(expected, actual, func(name string) (string, error) {
+		return FileChecksum(actual[name], algo)
+	})
+}
+
+// VerifyArchiveManifest checks the files inside the archive at archivePath against the manifest
+// embedded by CreateTarGzWithOptions or CreateZipWithOptions (ArchiveOptions.Manifest).
+// The archive is read twice, once for the manifest and once to hash the files.
+func VerifyArchiveManifest(archivePath string) (ManifestReport, error) {
+	var report ManifestReport
+
+	for _, algo := range []HashAlgorithm{SHA256, SHA1, MD5} {
+		var manifest bytes.Buffer
+		err := ExtractEntry(archivePath, algo.ManifestName(), &manifest)
+		if errors.Is(err, ErrEntryNotFound) {
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		expected, err := readManifest(&manifest)
+		if err != nil {
+			return report, err
+		}
+
+		sums := make(map[string]string)
+		err = WalkArchive(archivePath, func(entry ArchiveEntry, r io.Reader) error {
+			name := cleanArchiveName(entry.Name)
+			if entry.Type != EntryFile || name == algo.ManifestName() {
+				return nil
+			}
+			sum, err := ReaderChecksum(r, algo)
+			sums[name] = sum
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+
+		return compareManifest(expected, sums, func(name string) (string, error) {
+			return sums[name], nil
+		})
+	}
+
+	return report, fmt.Errorf("%s: no checksum manifest: %w", archivePath, ErrEntryNotFound)
+}
+
+// compareManifest builds the report for the expected checksums and the actual file names,
+// using checksum to compute the checksum of a file present on both sides.
+func compareManifest(expected map[string]string, actual map[string]string, checksum func(name string) (string, error)) (ManifestReport, error) {
+	var report ManifestReport
+
+	for name, want := range expected {
+		if _, ok := actual[name]; !ok {
+			report.Missing = append(report.Missing, name)
+			continue
+		}
+		got, err := checksum(name)
+		if err != nil {
+			return report, err
+		}
+		if strings.EqualFold(got, want) {
+			report.Verified = append(report.Verified, name)
+		} else {
+			report.Mismatched = append(report.Mismatched, name)
+		}
+	}
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			report.Extra = append(report.Extra, name)
+		}
+	}
+
+	sort.Strings(report.Verified)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.Mismatched)
+	return report, nil
+}
+
+// manifestEscaper and manifestUnescaper convert the names containing backslashes or line breaks,
+// which coreutils writes escaped on a line starting with a backslash
+var (
+	manifestEscaper   = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+	manifestUnescaper = strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\r", "\r")
+)
+
+// manifestLine formats the "<checksum>  <path>" line of a manifest, escaped like sha256sum does
+func manifestLine(sum, name string) string {
+	if escaped := manifestEscaper.Replace(name); escaped != name {
+		return fmt.Sprintf("\\%s  %s\n", sum, escaped)
+	}
+	return fmt.Sprintf("%s  %s\n", sum, name)
+}
+
+// readManifest parses "<checksum>  <path>" lines; the binary marker "*" before the path is accepted,
+// as well as the escaped form of coreutils, a backslash before the checksum
+func readManifest(r io.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(text, "\\")
+		sum, name, ok := strings.Cut(strings.TrimPrefix(text, "\\"), " ")
+		if !ok || sum == "" {
+			return nil, fmt.Errorf("invalid manifest line %d: %q", line, text)
+		}
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		if escaped {
+			name = manifestUnescaper.Replace(name)
+		}
+		sums[cleanArchiveName(name)] = sum
+	}
+	return sums, scanner.Err()
+}
+
+// manifestWriter collects the checksums of archived files and renders them as manifest
+type manifestWriter struct {
+	algo  HashAlgorithm
+	lines []string
+}
+
+// hash returns a new hash for the next file, or nil if no manifest is written
+func (m *manifestWriter) hash() hash.Hash {
+	if m == nil {
+		return nil
+	}
+	h, _ := m.algo.New()
+	return h
+}
+
+// add records the checksum of the archived file name
+func (m *manifestWriter) add(name string, h hash.Hash) {
+	if m == nil || h == nil {
+		return
+	}
+	m.lines = append(m.lines, manifestLine(hex.EncodeToString(h.Sum(nil)), path.Clean(name)))
+}
+
+// content returns the manifest file content
+func (m *manifestWriter) content() []byte {
+	return []byte(strings.Join(m.lines, ""))
+}
+
+// newManifestWriter returns a manifestWriter for the algorithm of opts, or nil if no manifest is wanted.
+// A file at the root of the archive named like the manifest is rejected, as it would be shadowed.
+func newManifestWriter(files []archiveFile, opts ArchiveOptions) (*manifestWriter, error) {
+	if opts.Manifest == "" {
+		return nil, nil
+	}
+	if _, err := opts.Manifest.New(); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if cleanArchiveName(file.name) == opts.Manifest.ManifestName() {
+			return nil, fmt.Errorf("%s conflicts with the checksum manifest of the archive", file.path)
+		}
+	}
+	return &manifestWriter{algo: opts.Manifest}, nil
+}
+
+// manifestModTime returns the modification time of the manifest entry: the configured time or the
+// newest file time for reproducible archives, the current time otherwise.
+func manifestModTime(files []archiveFile, opts ArchiveOptions) time.Time {
+	if !opts.Reproducible {
+		return time.Now().Truncate(time.Second)
+	}
+	if !opts.ModTime.IsZero() {
+		return opts.ModTime.Truncate(time.Second)
+	}
+	var newest time.Time
+	for _, file := range files {
+		if file.info.ModTime().After(newest) {
+			newest = file.info.ModTime()
+		}
+	}
+	return newest.Truncate(time.Second)
+}
@@ -0,0 +1,164 @@
+package filehelper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestTree creates the files, given by slash-separated path and content, below dir
+func writeTestTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name   string
+		change map[string]string // files written after the manifest, empty content removes the file
+		want   ManifestReport
+	}{
+		// the table itself
+		{"POS unchanged", nil, ManifestReport{Verified: []string{"a.txt", "sub/b.txt"}}},
+		{"NEG tampered", map[string]string{"sub/b.txt": "B"}, ManifestReport{Verified: []string{"a.txt"}, Mismatched: []string{"sub/b.txt"}}},
+		{"NEG missing", map[string]string{"a.txt": ""}, ManifestReport{Verified: []string{"sub/b.txt"}, Missing: []string{"a.txt"}}},
+		{"NEG extra", map[string]string{"c.txt": "c"}, ManifestReport{Verified: []string{"a.txt", "sub/b.txt"}, Extra: []string{"c.txt"}}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestTree(t, dir, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+			manifest := filepath.Join(dir, SHA256.ManifestName())
+			if err := WriteManifest(dir, manifest, SHA256); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, content := range tt.change {
+				if content == "" {
+					if err := os.Remove(filepath.Join(dir, name)); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				writeTestTree(t, dir, map[string]string{name: content})
+			}
+
+			got, err := VerifyManifest(dir, manifest, SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got.OK() != (tt.change == nil) {
+				t.Errorf("got OK %t", got.OK())
+			}
+		})
+	}
+}
+
+func TestManifestEscapedNames(t *testing.T) {
+	dir := t.TempDir()
+	odd := "odd\\name\nx.txt"
+	writeTestTree(t, dir, map[string]string{odd: "a", "plain.txt": "p"})
+	manifest := filepath.Join(t.TempDir(), SHA256.ManifestName())
+	if err := WriteManifest(dir, manifest, SHA256); err != nil {
+		t.Fatal(err)
+	}
+
+	// The line of the odd name is escaped like sha256sum does it
+	content, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\\ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  odd\\\\name\\nx.txt\n"
+	if !strings.Contains(string(content), want) {
+		t.Errorf("got manifest %q, want line %q", content, want)
+	}
+
+	got, err := VerifyManifest(dir, manifest, SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantReport := (ManifestReport{Verified: []string{odd, "plain.txt"}}); !reflect.DeepEqual(got, wantReport) {
+		t.Errorf("got %+v, want %+v", got, wantReport)
+	}
+
+	// The sidecar of a single file is escaped as well
+	sidecar, err := WriteChecksumFile(filepath.Join(dir, odd), SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChecksumFile(filepath.Join(dir, odd), SHA256); err != nil {
+		t.Errorf("VerifyChecksumFile(%s) error = %v", sidecar, err)
+	}
+}
+
+func TestVerifyArchiveManifest(t *testing.T) {
+	create := map[string]func(archive, source string, opts ArchiveOptions) error{
+		"tar.gz": func(archive, source string, opts ArchiveOptions) error {
+			return CreateTarGzWithOptions(archive, source, "", opts)
+		},
+		"zip": func(archive, source string, opts ArchiveOptions) error {
+			return CreateZipWithOptions(archive, source, "", opts)
+		},
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name  string
+		files map[string]string
+		want  []string // verified files, nil if creating the archive fails
+	}{
+		// the table itself
+		{"POS files", map[string]string{"a.txt": "a", "sub/b.txt": "b"}, []string{"a.txt", "sub/b.txt"}},
+		{"POS manifest name in subdirectory", map[string]string{"a.txt": "a", "sub/SHA256SUMS": "x"}, []string{"a.txt", "sub/SHA256SUMS"}},
+		{"NEG manifest name at root", map[string]string{"a.txt": "a", "SHA256SUMS": "x"}, nil},
+	}
+
+	// The execution loop
+	for format, createFn := range create {
+		for _, tt := range tests {
+			t.Run(format+" "+tt.name, func(t *testing.T) {
+				source := t.TempDir()
+				writeTestTree(t, source, tt.files)
+				archive := filepath.Join(t.TempDir(), "test."+format)
+
+				err := createFn(archive, source, ArchiveOptions{Manifest: SHA256})
+				if tt.want == nil {
+					if err == nil {
+						t.Fatal("got no error")
+					}
+					if _, err := os.Stat(archive); !os.IsNotExist(err) {
+						t.Errorf("archive was created: %v", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				report, err := VerifyArchiveManifest(archive)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !report.OK() || !reflect.DeepEqual(report.Verified, tt.want) {
+					t.Errorf("got %+v, want verified %v", report, tt.want)
+				}
+			})
+		}
+	}
+}
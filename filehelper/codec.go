@@ -0,0 +1,305 @@
+package filehelper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrUnknownFormat is returned when the magic bytes of a stream match no known codec
+	ErrUnknownFormat = errors.New("unknown compression format")
+	// ErrCompressUnsupported is returned by codecs which can only decompress (bzip2)
+	ErrCompressUnsupported = errors.New("compression not supported by codec")
+)
+
+// Codec is a compression format which can be detected by its magic bytes
+type Codec interface {
+	// Name returns the short name of the codec, e.g. "gzip"
+	Name() string
+	// Extension returns the file extension including the dot, e.g. ".gz"
+	Extension() string
+	// Magic returns the bytes every stream of the codec starts with
+	Magic() []byte
+	// NewReader returns a reader decompressing r
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	// NewWriter returns a writer compressing to w with the given level, see CompressOptions.Level.
+	// The data is only complete after Close.
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// The codecs supported by this package
+var (
+	Gzip  Codec = gzipCodec{}
+	Zstd  Codec = zstdCodec{}
+	Bzip2 Codec = bzip2Codec{}
+	Xz    Codec = xzCodec{}
+)
+
+// codecs lists the supported codecs in the order used for detection
+var codecs = []Codec{Gzip, Zstd, Bzip2, Xz}
+
+// maxMagicLen is the number of bytes needed to detect any of the codecs
+const maxMagicLen = 10
+
+// headerMatcher is implemented by codecs whose streams need more than a fixed prefix to be detected
+type headerMatcher interface {
+	matchHeader(header []byte) bool
+}
+
+// CompressOptions holds the options for CompressFile
+type CompressOptions struct {
+	Codec Codec // codec to use, Gzip if nil
+	Level int   // 1 (fastest) to 9 (best compression), 0 for the codec's default. Ignored by xz.
+}
+
+// CodecByName returns the codec with the given name ("gzip", "zstd", "bzip2" or "xz") or nil.
+func CodecByName(name string) Codec {
+	for _, c := range codecs {
+		if strings.EqualFold(c.Name(), name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// CodecByExtension returns the codec matching the extension of the file name, or nil.
+// Besides the plain extensions, the tar shorthands .tgz, .tzst, .tbz2 and .txz are recognized.
+func CodecByExtension(name string) Codec {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		return Gzip
+	case strings.HasSuffix(name, ".zst"), strings.HasSuffix(name, ".tzst"):
+		return Zstd
+	case strings.HasSuffix(name, ".bz2"), strings.HasSuffix(name, ".tbz2"):
+		return Bzip2
+	case strings.HasSuffix(name, ".xz"), strings.HasSuffix(name, ".txz"):
+		return Xz
+	}
+	return nil
+}
+
+// DetectCodec peeks at the first bytes of r and returns the codec whose magic bytes match,
+// or ErrUnknownFormat. No bytes are consumed from r.
+func DetectCodec(r *bufio.Reader) (Codec, error) {
+	header, err := r.Peek(maxMagicLen)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	for _, c := range codecs {
+		if m, ok := c.(headerMatcher); ok {
+			if m.matchHeader(header) {
+				return c, nil
+			}
+			continue
+		}
+		if bytes.HasPrefix(header, c.Magic()) {
+			return c, nil
+		}
+	}
+	return nil, ErrUnknownFormat
+}
+
+// NewDecompressReader detects the codec of r by its magic bytes and returns a reader
+// decompressing it, together with the detected codec.
+func NewDecompressReader(r io.Reader) (io.ReadCloser, Codec, error) {
+	br := bufio.NewReader(r)
+	codec, err := DetectCodec(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc, err := codec.NewReader(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rc, codec, nil
+}
+
+// Decompress detects the codec of in by its magic bytes, regardless of any file extension,
+// and writes the decompressed data to out.
+func Decompress(in io.Reader, out io.Writer) error {
+	rc, _, err := NewDecompressReader(in)
+	if err != nil {
+		return err
+	}
+	defer func(rc io.ReadCloser) {
+		_ = rc.Close()
+	}(rc)
+
+	_, err = io.Copy(out, rc)
+	return err
+}
+
+// Compress compresses in to out using the codec and level of opts
+func Compress(in io.Reader, out io.Writer, opts CompressOptions) error {
+	codec := opts.Codec
+	if codec == nil {
+		codec = Gzip
+	}
+
+	wc, err := codec.NewWriter(out, opts.Level)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(wc, in); err != nil {
+		_ = wc.Close()
+		return err
+	}
+	return wc.Close()
+}
+
+// CompressFile compresses the file at inputFilePath to outputFilePath using the codec and level of opts.
+// The output file is written atomically, see AtomicWriter.
+func CompressFile(inputFilePath, outputFilePath string, opts CompressOptions) error {
+	inputFile, err := os.Open(inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer func(inputFile *os.File) {
+		_ = inputFile.Close()
+	}(inputFile)
+
+	outputFile, err := NewAtomicWriter(outputFilePath, 0644)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Abort()
+
+	if err := Compress(inputFile, outputFile, opts); err != nil {
+		return err
+	}
+	return outputFile.Close()
+}
+
+// DecompressFile decompresses the file at inputFilePath to outputFilePath. The codec is
+// detected from the magic bytes of the file, so the extension does not matter.
+// The output file is written atomically, see AtomicWriter.
+func DecompressFile(inputFilePath, outputFilePath string) error {
+	inputFile, err := os.Open(inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer func(inputFile *os.File) {
+		_ = inputFile.Close()
+	}(inputFile)
+
+	outputFile, err := NewAtomicWriter(outputFilePath, 0644)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Abort()
+
+	if err := Decompress(inputFile, outputFile); err != nil {
+		return err
+	}
+	return outputFile.Close()
+}
+
+// gzipCodec implements Codec for gzip using compress/gzip
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string      { return "gzip" }
+func (gzipCodec) Extension() string { return ".gz" }
+func (gzipCodec) Magic() []byte     { return []byte{0x1f, 0x8b} }
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// zstdCodec implements Codec for Zstandard using klauspost/compress
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string      { return "zstd" }
+func (zstdCodec) Extension() string { return ".zst" }
+func (zstdCodec) Magic() []byte     { return []byte{0x28, 0xb5, 0x2f, 0xfd} }
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+func (zstdCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	encoderLevel := zstd.SpeedDefault
+	switch {
+	case level < 0 || level > 9:
+		return nil, fmt.Errorf("invalid compression level %d", level)
+	case level == 0:
+	case level <= 2:
+		encoderLevel = zstd.SpeedFastest
+	case level <= 5:
+		encoderLevel = zstd.SpeedDefault
+	case level <= 7:
+		encoderLevel = zstd.SpeedBetterCompression
+	default:
+		encoderLevel = zstd.SpeedBestCompression
+	}
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(encoderLevel))
+}
+
+// bzip2Codec implements Codec for bzip2 using compress/bzip2, which can only decompress
+type bzip2Codec struct{}
+
+func (bzip2Codec) Name() string      { return "bzip2" }
+func (bzip2Codec) Extension() string { return ".bz2" }
+func (bzip2Codec) Magic() []byte     { return []byte("BZh") }
+
+// Block magic (pi) and end of stream magic (sqrt(pi)), one of them follows the bzip2 header
+var (
+	bzip2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2EndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// matchHeader checks "BZh", the block size digit 1-9 and the magic of the first block, so plain
+// text starting with "BZh" is not taken for bzip2
+func (c bzip2Codec) matchHeader(header []byte) bool {
+	if len(header) < 10 || !bytes.HasPrefix(header, c.Magic()) || header[3] < '1' || header[3] > '9' {
+		return false
+	}
+	return bytes.Equal(header[4:10], bzip2BlockMagic) || bytes.Equal(header[4:10], bzip2EndMagic)
+}
+
+func (bzip2Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(bzip2.NewReader(r)), nil
+}
+
+func (bzip2Codec) NewWriter(io.Writer, int) (io.WriteCloser, error) {
+	return nil, ErrCompressUnsupported
+}
+
+// xzCodec implements Codec for xz using ulikunitz/xz
+type xzCodec struct{}
+
+func (xzCodec) Name() string      { return "xz" }
+func (xzCodec) Extension() string { return ".xz" }
+func (xzCodec) Magic() []byte     { return []byte{0xfd, '7', 'z', 'X', 'Z', 0x00} }
+
+func (xzCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(xr), nil
+}
+
+func (xzCodec) NewWriter(w io.Writer, _ int) (io.WriteCloser, error) {
+	return xz.NewWriter(w)
+}
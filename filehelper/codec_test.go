@@ -0,0 +1,95 @@
+package filehelper
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("go-helpers codec test "), 1000)
+
+	for _, codec := range []Codec{Gzip, Zstd, Xz} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			var compressed, plain bytes.Buffer
+			if err := Compress(bytes.NewReader(data), &compressed, CompressOptions{Codec: codec, Level: 9}); err != nil {
+				t.Fatal(err)
+			}
+			if err := Decompress(&compressed, &plain); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plain.Bytes(), data) {
+				t.Errorf("got %d bytes, want %d", plain.Len(), len(data))
+			}
+		})
+	}
+
+	if err := Decompress(bytes.NewReader(data), &bytes.Buffer{}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("got %v, want %v", err, ErrUnknownFormat)
+	}
+	if _, err := Bzip2.NewWriter(&bytes.Buffer{}, 0); !errors.Is(err, ErrCompressUnsupported) {
+		t.Errorf("got %v, want %v", err, ErrCompressUnsupported)
+	}
+}
+
+func TestDetectCodec(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name   string
+		header []byte
+		want   Codec
+	}{
+		// the table itself
+		{"POS gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, Gzip},
+		{"POS zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, Zstd},
+		{"POS bzip2", append([]byte("BZh9"), 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x00), Bzip2},
+		{"POS bzip2 empty", append([]byte("BZh1"), 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x00), Bzip2},
+		{"POS xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00}, Xz},
+		{"NEG text starting with BZh", []byte("BZhello, this is plain text"), nil},
+		{"NEG bzip2 block size 0", append([]byte("BZh0"), 0x31, 0x41, 0x59, 0x26, 0x53, 0x59), nil},
+		{"NEG bzip2 truncated", []byte("BZh9"), nil},
+		{"NEG empty", []byte{}, nil},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectCodec(bufio.NewReader(bytes.NewReader(tt.header)))
+			if tt.want == nil {
+				if !errors.Is(err, ErrUnknownFormat) {
+					t.Errorf("got %v : %v, want %v", got, err, ErrUnknownFormat)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %v : %v, want %s", got, err, tt.want.Name())
+			}
+		})
+	}
+}
+
+func TestExtractTarZst(t *testing.T) {
+	// Recompress a tar.gz test archive with zstd
+	var tarData, zstData bytes.Buffer
+	archive := buildTarGz(t, []tarGzEntry{{name: "a.txt", typeflag: tar.TypeReg, content: []byte("a")}})
+	if err := Decompress(bytes.NewReader(archive), &tarData); err != nil {
+		t.Fatal(err)
+	}
+	if err := Compress(bytes.NewReader(tarData.Bytes()), &zstData, CompressOptions{Codec: Zstd}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, input := range map[string][]byte{"tar": tarData.Bytes(), "zst": zstData.Bytes()} {
+		dest := t.TempDir()
+		if err := ExtractTar(bytes.NewReader(input), dest); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, err := os.ReadFile(filepath.Join(dest, "a.txt")); err != nil || string(got) != "a" {
+			t.Errorf("%s: got %q : %v", name, got, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package filehelper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictPolicy defines what happens when a file is written to a path which already exists
+type ConflictPolicy int
+
+const (
+	ConflictOverwrite ConflictPolicy = iota // replace the existing file
+	ConflictSkip                            // keep the existing file and skip the new one
+	ConflictFail                            // stop with an error wrapping os.ErrExist
+	ConflictRename                          // write the new file with a numeric suffix, e.g. "report_1.csv"
+)
+
+// String returns the name of the policy
+func (p ConflictPolicy) String() string {
+	switch p {
+	case ConflictOverwrite:
+		return "overwrite"
+	case ConflictSkip:
+		return "skip"
+	case ConflictFail:
+		return "fail"
+	case ConflictRename:
+		return "rename"
+	default:
+		return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+	}
+}
+
+// uniquePath returns the first path derived from path by adding "_1", "_2", ... in front of
+// the extension which does not exist yet. For "data.tar.gz" the suffix goes before ".tar.gz".
+func uniquePath(path string) (string, error) {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	if strings.HasSuffix(strings.ToLower(name), ".tar"+strings.ToLower(ext)) && ext != "" {
+		ext = name[len(name)-len(".tar")-len(ext):]
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; ; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i, ext))
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package filehelper
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// DirOptions controls CopyDir, MoveDir and MirrorDir
+type DirOptions struct {
+	Include []string // glob patterns, only files matching at least one are transferred; empty transfers all
+	Exclude []string // glob patterns of files and directories to leave out (and to keep in the destination when mirroring)
+	Workers int      // number of files transferred at the same time, GOMAXPROCS if 0
+	DryRun  bool     // only report what would be done
+	Copy    CopyOptions
+}
+
+// DirSummary reports the result of CopyDir, MoveDir or MirrorDir, e.g. for logging
+type DirSummary struct {
+	Files   int     // files copied or moved
+	Bytes   int64   // bytes copied or moved
+	Skipped int     // files left out because of the conflict policy
+	Deleted int     // files and directories deleted from the destination (mirror only)
+	Errors  []error // errors of single files, the transfer continues with the next file
+}
+
+// String returns a short one-line summary
+func (s DirSummary) String() string {
+	return fmt.Sprintf("%d files, %d bytes, %d skipped, %d deleted, %d errors", s.Files, s.Bytes, s.Skipped, s.Deleted, len(s.Errors))
+}
+
+// Err returns the errors of the summary joined into one, or nil if there were none
+func (s DirSummary) Err() error {
+	return errors.Join(s.Errors...)
+}
+
+// CopyDir copies the directory tree at srcDir into destDir, preserving the structure, permissions and
+// modification times. Files are copied by a bounded pool of workers through an AtomicWriter, existing
+// files are handled according to opts.Copy.Conflict. The glob patterns of opts are matched like in
+// ListFiles; excluded directories are not descended into.
+//
+// Errors of single files do not stop the copy, they are collected in the summary. The returned error
+// is only set if the source tree cannot be walked.
+func CopyDir(srcDir, destDir string, opts DirOptions) (DirSummary, error) {
+	summary, _, err := transferDir(srcDir, destDir, opts, transferCopy)
+	return summary, err
+}
+
+// MoveDir works like CopyDir, but moves the files, renaming them where possible (see MoveFileWithOptions).
+// Source directories which are empty afterwards are removed, srcDir itself included.
+func MoveDir(srcDir, destDir string, opts DirOptions) (DirSummary, error) {
+	summary, dirs, err := transferDir(srcDir, destDir, opts, transferMove)
+	if err != nil || opts.DryRun {
+		return summary, err
+	}
+
+	// Deepest first, non-empty directories stay
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i].src)
+	}
+	return summary, nil
+}
+
+// MirrorDir makes destDir an exact copy of srcDir: it copies like CopyDir and then deletes all files and
+// directories from destDir which do not exist in srcDir. Paths matching opts.Exclude are neither copied
+// nor deleted. Files in destDir are replaced if their size or modification time differs from the source,
+// unchanged files are skipped regardless of the conflict policy.
+func MirrorDir(srcDir, destDir string, opts DirOptions) (DirSummary, error) {
+	opts.Copy.Conflict = ConflictOverwrite
+	summary, _, err := transferDir(srcDir, destDir, opts, transferMirror)
+	if err != nil {
+		return summary, err
+	}
+
+	var extraneous []string
+	err = filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == destDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if path == destDir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(destDir, path)
+		if err != nil {
+			return err
+		}
+		if matchAnyGlob(opts.Exclude, d.Name(), filepath.ToSlash(rel)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if _, err := os.Lstat(filepath.Join(srcDir, rel)); os.IsNotExist(err) {
+			extraneous = append(extraneous, path)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	for _, path := range extraneous {
+		summary.Deleted++
+		if opts.DryRun {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			summary.Errors = append(summary.Errors, err)
+		}
+	}
+
+	return summary, nil
+}
+
+// transferMode selects what transferDir does with the files
+type transferMode int
+
+const (
+	transferCopy transferMode = iota
+	transferMove
+	transferMirror
+)
+
+// dirTask is a single file or directory to transfer
+type dirTask struct {
+	src  string
+	dest string
+	info os.FileInfo
+}
+
+// transferDir walks srcDir, creates the directories in destDir and copies or moves the files with a
+// worker pool. It returns the summary and the source directories in walk order.
+func transferDir(srcDir, destDir string, opts DirOptions, mode transferMode) (DirSummary, []dirTask, error) {
+	var summary DirSummary
+
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return summary, nil, err
+		}
+	}
+
+	var tasks []dirTask
+	var dirs []dirTask
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && matchAnyGlob(opts.Exclude, info.Name(), filepath.ToSlash(rel)) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		dest := filepath.Join(destDir, rel)
+		switch {
+		case info.IsDir():
+			dirs = append(dirs, dirTask{src: path, dest: dest, info: info})
+		case !info.Mode().IsRegular():
+			// symlinks, devices and other special files are not transferred
+		case len(opts.Include) == 0 || matchAnyGlob(opts.Include, info.Name(), filepath.ToSlash(rel)):
+			tasks = append(tasks, dirTask{src: path, dest: dest, info: info})
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, nil, err
+	}
+
+	if opts.DryRun {
+		for _, task := range tasks {
+			if skipTransfer(task, opts.Copy.Conflict, mode) {
+				summary.Skipped++
+				continue
+			}
+			summary.Files++
+			summary.Bytes += task.info.Size()
+		}
+		return summary, dirs, nil
+	}
+
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir.dest, 0755); err != nil {
+			return summary, nil, err
+		}
+	}
+
+	var mu sync.Mutex
+	runWorkers(tasks, opts.Workers, func(task dirTask) {
+		var action EntryAction
+		var err error
+		switch {
+		case mode == transferMirror && skipTransfer(task, opts.Copy.Conflict, mode):
+			action = ActionSkipped
+		case mode == transferMove:
+			_, action, err = MoveFileWithOptions(task.src, filepath.Dir(task.dest), filepath.Base(task.dest), opts.Copy)
+		default:
+			_, action, err = CopyFileWithOptions(task.src, filepath.Dir(task.dest), filepath.Base(task.dest), opts.Copy)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			summary.Errors = append(summary.Errors, err)
+		case action == ActionSkipped:
+			summary.Skipped++
+		default:
+			summary.Files++
+			summary.Bytes += task.info.Size()
+		}
+	})
+
+	// Apply directory metadata last, deepest first, because adding files changes the modification time
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].dest, dirs[i].info.Mode().Perm()); err != nil {
+			summary.Errors = append(summary.Errors, err)
+		}
+		if err := os.Chtimes(dirs[i].dest, dirs[i].info.ModTime(), dirs[i].info.ModTime()); err != nil {
+			summary.Errors = append(summary.Errors, err)
+		}
+	}
+
+	return summary, dirs, nil
+}
+
+// skipTransfer reports whether a file is left out: because its destination exists and the conflict
+// policy is ConflictSkip, or because it is unchanged (same size and modification time) when mirroring.
+func skipTransfer(task dirTask, policy ConflictPolicy, mode transferMode) bool {
+	info, err := os.Stat(task.dest)
+	if err != nil {
+		return false
+	}
+	if mode == transferMirror {
+		return info.Mode().IsRegular() && info.Size() == task.info.Size() && info.ModTime().Equal(task.info.ModTime())
+	}
+	return policy == ConflictSkip
+}
+
+// runWorkers calls fn for every task using at most workers goroutines (GOMAXPROCS if 0)
+func runWorkers(tasks []dirTask, workers int, fn func(task dirTask)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	queue := make(chan dirTask)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				fn(task)
+			}
+		}()
+	}
+
+	for _, task := range tasks {
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
+}
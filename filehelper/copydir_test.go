@@ -0,0 +1,228 @@
+package filehelper
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// readTestTree returns the files below dir by slash-separated relative path and their content
+func readTestTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestCopyDir(t *testing.T) {
+	source := map[string]string{"a.csv": "a", "sub/b.csv": "bb", "sub/c.log": "ccc", "tmp/d.csv": "dddd"}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name     string
+		existing map[string]string // files in the destination before the copy
+		opts     DirOptions
+		want     map[string]string // files in the destination afterwards
+		summary  DirSummary
+	}{
+		// the table itself
+		{"POS all", nil, DirOptions{},
+			source, DirSummary{Files: 4, Bytes: 10}},
+		{"POS include", nil, DirOptions{Include: []string{"*.csv"}},
+			map[string]string{"a.csv": "a", "sub/b.csv": "bb", "tmp/d.csv": "dddd"}, DirSummary{Files: 3, Bytes: 7}},
+		{"POS exclude directory", nil, DirOptions{Exclude: []string{"tmp"}},
+			map[string]string{"a.csv": "a", "sub/b.csv": "bb", "sub/c.log": "ccc"}, DirSummary{Files: 3, Bytes: 6}},
+		{"POS skip existing", map[string]string{"a.csv": "old"}, DirOptions{Copy: CopyOptions{Conflict: ConflictSkip}},
+			map[string]string{"a.csv": "old", "sub/b.csv": "bb", "sub/c.log": "ccc", "tmp/d.csv": "dddd"}, DirSummary{Files: 3, Bytes: 9, Skipped: 1}},
+		{"POS dry run", map[string]string{"a.csv": "old"}, DirOptions{DryRun: true, Copy: CopyOptions{Conflict: ConflictSkip}},
+			map[string]string{"a.csv": "old"}, DirSummary{Files: 3, Bytes: 9, Skipped: 1}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dest := t.TempDir(), t.TempDir()
+			writeTestTree(t, src, source)
+			writeTestTree(t, dest, tt.existing)
+
+			summary, err := CopyDir(src, dest, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(summary, tt.summary) {
+				t.Errorf("got %v, want %v", summary, tt.summary)
+			}
+			if got := readTestTree(t, dest); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if got := readTestTree(t, src); !reflect.DeepEqual(got, source) {
+				t.Errorf("source changed to %v", got)
+			}
+		})
+	}
+}
+
+func TestCopyDirErrors(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files["f"+strconv.Itoa(i)] = "x"
+	}
+	writeTestTree(t, src, files)
+	writeTestTree(t, dest, map[string]string{"f3": "old", "f7": "old", "f11": "old", "f19": "old"})
+
+	// Every conflict is an error of its own, the other files are copied nevertheless
+	summary, err := CopyDir(src, dest, DirOptions{Workers: 4, Copy: CopyOptions{Conflict: ConflictFail}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Files != 16 || len(summary.Errors) != 4 {
+		t.Errorf("got %v, want 16 files and 4 errors", summary)
+	}
+	if !errors.Is(summary.Err(), os.ErrExist) {
+		t.Errorf("got %v, want %v", summary.Err(), os.ErrExist)
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	writeTestTree(t, src, map[string]string{"a.csv": "a", "sub/b.csv": "bb", "keep/c.log": "ccc"})
+
+	summary, err := MoveDir(src, dest, DirOptions{Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Files != 2 || summary.Bytes != 3 || len(summary.Errors) != 0 {
+		t.Errorf("got %v, want 2 files and 3 bytes", summary)
+	}
+	if got, want := readTestTree(t, dest), map[string]string{"a.csv": "a", "sub/b.csv": "bb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Emptied directories are removed, the one with the excluded file stays
+	if got, want := readTestTree(t, src), map[string]string{"keep/c.log": "ccc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got source %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(src, "sub")); !os.IsNotExist(err) {
+		t.Errorf("got %v, want the emptied directory removed", err)
+	}
+}
+
+func TestMirrorDir(t *testing.T) {
+	modTime := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		dryRun  bool
+		want    map[string]string
+		summary DirSummary
+	}{
+		// the table itself
+		{"POS mirror", false,
+			map[string]string{"same.csv": "same", "changed.csv": "new", "new.csv": "n", "keep.log": "log"},
+			DirSummary{Files: 2, Bytes: 4, Skipped: 1, Deleted: 2}},
+		{"POS dry run", true,
+			map[string]string{"same.csv": "same", "changed.csv": "older", "gone.csv": "g", "old/x.csv": "x", "keep.log": "log"},
+			DirSummary{Files: 2, Bytes: 4, Skipped: 1, Deleted: 2}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dest := t.TempDir(), t.TempDir()
+			writeTestTree(t, src, map[string]string{"same.csv": "same", "changed.csv": "new", "new.csv": "n"})
+			writeTestTree(t, dest, map[string]string{"same.csv": "same", "changed.csv": "older", "gone.csv": "g", "old/x.csv": "x", "keep.log": "log"})
+			for _, name := range []string{"same.csv", "changed.csv"} {
+				for _, dir := range []string{src, dest} {
+					if err := os.Chtimes(filepath.Join(dir, name), modTime, modTime); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			summary, err := MirrorDir(src, dest, DirOptions{Exclude: []string{"*.log"}, DryRun: tt.dryRun, Copy: CopyOptions{Conflict: ConflictFail}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// changed.csv has the same modification time, but another size
+			if !reflect.DeepEqual(summary, tt.summary) {
+				t.Errorf("got %v, want %v", summary, tt.summary)
+			}
+			if got := readTestTree(t, dest); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		tasks   int
+		workers int
+	}{
+		// the table itself
+		{"POS more tasks than workers", 50, 4},
+		{"POS more workers than tasks", 3, 8},
+		{"POS default workers", 20, 0},
+		{"POS no tasks", 0, 2},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := make([]dirTask, tt.tasks)
+			for i := range tasks {
+				tasks[i] = dirTask{src: strconv.Itoa(i)}
+			}
+
+			var active, maxActive int32
+			var mu sync.Mutex
+			seen := make(map[string]bool)
+			runWorkers(tasks, tt.workers, func(task dirTask) {
+				n := atomic.AddInt32(&active, 1)
+				for {
+					old := atomic.LoadInt32(&maxActive)
+					if n <= old || atomic.CompareAndSwapInt32(&maxActive, old, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&active, -1)
+
+				mu.Lock()
+				seen[task.src] = true
+				mu.Unlock()
+			})
+
+			if len(seen) != tt.tasks {
+				t.Errorf("got %d tasks run, want %d", len(seen), tt.tasks)
+			}
+			if tt.workers > 0 && int(maxActive) > tt.workers {
+				t.Errorf("got %d concurrent tasks, want at most %d", maxActive, tt.workers)
+			}
+		})
+	}
+}
@@ -24,8 +24,19 @@ import (
 // detailing the cause of the failure.
 //
 // It's important to use this function responsibly, considering it will permanently
-// delete files.
+// delete files. Use DeleteFilesInDirWithOptions to move them into a Trash instead.
 func DeleteFilesInDir(dirPath string, extensions []string) error {
+	return DeleteFilesInDirWithOptions(dirPath, extensions, DeleteOptions{})
+}
+
+// DeleteOptions controls how files are deleted.
+// The zero value deletes permanently.
+type DeleteOptions struct {
+	Trash *Trash // move the files into this trash instead of removing them
+}
+
+// DeleteFilesInDirWithOptions works like DeleteFilesInDir, but can move the files into a trash.
+func DeleteFilesInDirWithOptions(dirPath string, extensions []string, opts DeleteOptions) error {
 	extMap := make(map[string]bool)
 	for _, ext := range extensions {
 		// Ensure extensions start with '.'
@@ -46,7 +57,7 @@ func DeleteFilesInDir(dirPath string, extensions []string) error {
 		}
 		ext := strings.ToLower(filepath.Ext(d.Name()))
 		if _, ok := extMap[ext]; ok {
-			err := os.Remove(filepath.Join(dirPath, d.Name()))
+			err := removeOrTrash(filepath.Join(dirPath, d.Name()), opts.Trash)
 			if err != nil {
 				return err
 			}
@@ -70,6 +81,12 @@ func DeleteFilesInDir(dirPath string, extensions []string) error {
 // The function returns an error if any occurs during directory traversal or file deletion.
 // If no error occurs, the function returns nil indicating successful file deletion.
 func DeleteFilesInDirRecursive(dirPath string, extensions []string) error {
+	return DeleteFilesInDirRecursiveWithOptions(dirPath, extensions, DeleteOptions{})
+}
+
+// DeleteFilesInDirRecursiveWithOptions works like DeleteFilesInDirRecursive, but can move the files
+// into a trash. A trash directory below dirPath is skipped.
+func DeleteFilesInDirRecursiveWithOptions(dirPath string, extensions []string, opts DeleteOptions) error {
 	extMap := make(map[string]bool)
 	for _, ext := range extensions {
 		// Ensure extensions start with '.'
@@ -83,12 +100,15 @@ func DeleteFilesInDirRecursive(dirPath string, extensions []string) error {
 		if err != nil {
 			return err
 		}
+		if d.IsDir() && inTrash(path, opts.Trash) {
+			return filepath.SkipDir
+		}
 
 		// If it is not a directory, then it is a file, delete it if its extension is in the map
 		if !d.IsDir() {
 			ext := strings.ToLower(filepath.Ext(path))
 			if _, ok := extMap[ext]; ok {
-				err := os.Remove(path)
+				err := removeOrTrash(path, opts.Trash)
 				if err != nil {
 					return err
 				}
@@ -0,0 +1,257 @@
+package filehelper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrAbsolutePath is reported for archive entries with an absolute path
+	ErrAbsolutePath = errors.New("absolute path in archive")
+	// ErrPathTraversal is reported for archive entries whose path leaves the destination directory
+	ErrPathTraversal = errors.New("path escapes destination directory")
+	// ErrLinkEscape is reported for links pointing outside the destination directory
+	ErrLinkEscape = errors.New("link target escapes destination directory")
+	// ErrTooManyFiles is reported when an archive has more entries than ExtractOptions.MaxFiles
+	ErrTooManyFiles = errors.New("too many files in archive")
+	// ErrTooLarge is reported when an archive expands to more than ExtractOptions.MaxTotalBytes
+	ErrTooLarge = errors.New("archive too large")
+	// ErrCompressionRatio is reported when an archive expands more than ExtractOptions.MaxRatio
+	ErrCompressionRatio = errors.New("compression ratio too high")
+)
+
+// ratioThreshold is the number of uncompressed bytes after which the compression ratio is checked,
+// so small highly compressible archives are not rejected.
+const ratioThreshold = 1 << 20
+
+// UnsafeEntryError describes an archive entry which was rejected during extraction.
+// Err is one of the Err* values of this package and can be checked with errors.Is.
+type UnsafeEntryError struct {
+	Name     string // name of the entry in the archive
+	Linkname string // link target of the entry, if it is a link
+	Err      error  // reason for the rejection
+}
+
+// Error implements the error interface
+func (e *UnsafeEntryError) Error() string {
+	if e.Linkname != "" {
+		return fmt.Sprintf("unsafe archive entry %q -> %q: %v", e.Name, e.Linkname, e.Err)
+	}
+	return fmt.Sprintf("unsafe archive entry %q: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the reason for the rejection
+func (e *UnsafeEntryError) Unwrap() error {
+	return e.Err
+}
+
+// safeJoin joins the archive entry name onto dest and rejects absolute names and names leaving dest
+func safeJoin(dest, name string) (string, error) {
+	clean, err := cleanEntryName(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dest, clean), nil
+}
+
+// cleanEntryName returns the cleaned, OS-specific relative form of an archive entry name
+func cleanEntryName(name string) (string, error) {
+	native := filepath.FromSlash(name)
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(native) || filepath.VolumeName(native) != "" {
+		return "", ErrAbsolutePath
+	}
+	clean := filepath.Clean(native)
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", ErrPathTraversal
+	}
+	return clean, nil
+}
+
+// checkSymlinkTarget rejects symlink entries pointing outside dest. The target of a symlink
+// is relative to the directory of the entry, absolute targets are always rejected.
+func checkSymlinkTarget(name, linkname string) error {
+	if strings.HasPrefix(linkname, "/") || filepath.IsAbs(filepath.FromSlash(linkname)) {
+		return ErrLinkEscape
+	}
+	clean, err := cleanEntryName(name)
+	if err != nil {
+		return err
+	}
+	if _, err := cleanEntryName(filepath.Join(filepath.Dir(clean), filepath.FromSlash(linkname))); err != nil {
+		return ErrLinkEscape
+	}
+	return nil
+}
+
+// checkNoSymlinkEscape makes sure that no symlink already existing below dest redirects
+// target to a location outside of dest.
+func checkNoSymlinkEscape(dest, target string) error {
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	// Find the deepest existing ancestor of the target and resolve it
+	dir := filepath.Dir(target)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ErrLinkEscape
+	}
+	return nil
+}
+
+// checkSymlinkResolved makes sure a symlink at target pointing to linkname stays inside dest once
+// the symlinks already extracted are followed. The link target is resolved component by component,
+// starting at the real parent directory of target, so chains like "a -> ." and "a/b -> ../x" are caught.
+func checkSymlinkResolved(dest, target, linkname string) error {
+	root, err := resolvedDir(dest)
+	if err != nil {
+		return err
+	}
+	current, err := resolvedDir(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if !isBelow(root, current) {
+		return ErrLinkEscape
+	}
+
+	for _, part := range strings.Split(filepath.FromSlash(linkname), string(filepath.Separator)) {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+		default:
+			current = filepath.Join(current, part)
+			if info, err := os.Lstat(current); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				resolved, err := filepath.EvalSymlinks(current)
+				if err != nil {
+					// A dangling link leads nowhere yet, but must not be allowed to lead outside later
+					return ErrLinkEscape
+				}
+				if current, err = filepath.Abs(resolved); err != nil {
+					return err
+				}
+			}
+		}
+		if !isBelow(root, current) {
+			return ErrLinkEscape
+		}
+	}
+	return nil
+}
+
+// resolvedDir returns the absolute path of dir with all symlinks resolved
+func resolvedDir(dir string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(resolved)
+}
+
+// isBelow reports whether path is root or inside it, both absolute and clean
+func isBelow(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// countingReader counts the bytes read from the underlying reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// extractGuard enforces the limits of ExtractOptions during an extraction
+type extractGuard struct {
+	opts       ExtractOptions
+	compressed func() int64 // returns the number of compressed bytes consumed so far, nil if unknown
+	files      int
+	written    int64
+}
+
+// newExtractGuard wraps the compressed input, so the compression ratio can be checked
+func newExtractGuard(compressed io.Reader, opts ExtractOptions) (*extractGuard, io.Reader) {
+	counter := &countingReader{r: compressed}
+	return &extractGuard{opts: opts, compressed: func() int64 { return counter.n }}, counter
+}
+
+// entry is called for every archive entry and checks the file count and the announced size
+func (g *extractGuard) entry(name string, size int64) error {
+	g.files++
+	if g.opts.MaxFiles > 0 && g.files > g.opts.MaxFiles {
+		return &UnsafeEntryError{Name: name, Err: ErrTooManyFiles}
+	}
+	if g.opts.MaxTotalBytes > 0 && g.written+size > g.opts.MaxTotalBytes {
+		return &UnsafeEntryError{Name: name, Err: ErrTooLarge}
+	}
+	return nil
+}
+
+// copy copies the entry content from src to dst and checks the size and ratio limits as it goes
+func (g *extractGuard) copy(name string, dst io.Writer, src io.Reader) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var total int64
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			g.written += int64(n)
+			if g.opts.MaxTotalBytes > 0 && g.written > g.opts.MaxTotalBytes {
+				return total, &UnsafeEntryError{Name: name, Err: ErrTooLarge}
+			}
+			if g.opts.MaxRatio > 0 && g.compressed != nil && g.written > ratioThreshold &&
+				g.written > g.opts.MaxRatio*g.compressed() {
+				return total, &UnsafeEntryError{Name: name, Err: ErrCompressionRatio}
+			}
+			written, err := dst.Write(buf[:n])
+			total += int64(written)
+			if err != nil {
+				return total, err
+			}
+			if written != n {
+				return total, io.ErrShortWrite
+			}
+		}
+		if readErr == io.EOF {
+			return total, nil
+		}
+		if readErr != nil {
+			return total, readErr
+		}
+	}
+}
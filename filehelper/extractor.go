@@ -0,0 +1,386 @@
+package filehelper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EntryType is the kind of an archive entry
+type EntryType int
+
+const (
+	EntryFile     EntryType = iota // regular file
+	EntryDir                       // directory
+	EntrySymlink                   // symbolic link
+	EntryHardlink                  // hard link to an earlier entry
+	EntryOther                     // devices, fifos and other special files, never extracted
+)
+
+// String returns the name of the entry type
+func (t EntryType) String() string {
+	switch t {
+	case EntryFile:
+		return "file"
+	case EntryDir:
+		return "dir"
+	case EntrySymlink:
+		return "symlink"
+	case EntryHardlink:
+		return "hardlink"
+	default:
+		return "other"
+	}
+}
+
+// ArchiveEntry describes a single entry of an archive
+type ArchiveEntry struct {
+	Name     string      // slash-separated path inside the archive
+	Type     EntryType   // kind of the entry
+	Size     int64       // uncompressed size in bytes
+	Mode     os.FileMode // permission and mode bits
+	ModTime  time.Time   // modification time
+	Linkname string      // target of a symlink or hard link
+	Uid      int         // user id of the owner
+	Gid      int         // group id of the owner
+}
+
+// EntryAction tells what happened to an entry during extraction
+type EntryAction int
+
+const (
+	ActionCreated     EntryAction = iota // the entry was written to a new path
+	ActionOverwritten                    // an existing file was replaced
+	ActionMerged                         // the directory existed already, its metadata was updated
+	ActionSkipped                        // the entry was not written
+	ActionRenamed                        // the entry was written to a new path with a numeric suffix
+)
+
+// String returns the name of the action
+func (a EntryAction) String() string {
+	switch a {
+	case ActionCreated:
+		return "created"
+	case ActionOverwritten:
+		return "overwritten"
+	case ActionMerged:
+		return "merged"
+	case ActionSkipped:
+		return "skipped"
+	case ActionRenamed:
+		return "renamed"
+	default:
+		return fmt.Sprintf("EntryAction(%d)", int(a))
+	}
+}
+
+// ExtractOptions controls how an archive is extracted. The zero value extracts everything
+// including links, creates missing parent directories, overwrites existing files, restores
+// permissions (minus the umask of the process) and modification times and applies no size limits.
+type ExtractOptions struct {
+	MaxTotalBytes int64 // maximum number of bytes written for all entries together, 0 for no limit
+	MaxFiles      int   // maximum number of entries in the archive, 0 for no limit
+	MaxRatio      int64 // maximum ratio of uncompressed to compressed bytes (zip bomb defense), 0 for no limit
+
+	Overwrite       ConflictPolicy // what to do with entries whose path exists already
+	SkipSymlinks    bool           // do not create symbolic links
+	SkipHardlinks   bool           // do not create hard links
+	NoCreateParents bool           // fail instead of creating parent directories missing in the archive
+	SkipPermissions bool           // use 0644 for files and 0755 for directories instead of the archived modes (both minus umask)
+	SkipMtime       bool           // do not restore modification times
+	PreserveOwner   bool           // restore uid/gid and setuid/setgid/sticky bits, usually requires root
+
+	// OnEntry is called for every entry after it has been processed, e.g. for logging.
+	// target is the path the entry was written to, or would have been written to if it was skipped.
+	OnEntry func(entry ArchiveEntry, target string, action EntryAction)
+}
+
+// dirMeta remembers a directory whose metadata is applied after all entries are extracted
+type dirMeta struct {
+	target string
+	entry  ArchiveEntry
+}
+
+// extractor writes archive entries below dest. It is shared by all archive formats.
+type extractor struct {
+	dest  string
+	opts  ExtractOptions
+	guard *extractGuard
+	dirs  []dirMeta
+
+	// moved maps directory entries which were in the way of a file to where their content goes
+	// according to the conflict policy: the renamed directory, or "" if it was skipped
+	moved map[string]string
+}
+
+// newExtractor creates the destination directory and returns an extractor writing into it
+func newExtractor(dest string, opts ExtractOptions, guard *extractGuard) (*extractor, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+	return &extractor{dest: dest, opts: opts, guard: guard, moved: make(map[string]string)}, nil
+}
+
+// extract validates the entry and writes it to disk, reading file content from content
+func (x *extractor) extract(entry ArchiveEntry, content io.Reader) error {
+	if err := x.guard.entry(entry.Name, entry.Size); err != nil {
+		return err
+	}
+
+	original, err := safeJoin(x.dest, entry.Name)
+	if err != nil {
+		return &UnsafeEntryError{Name: entry.Name, Err: err}
+	}
+	target, ok := x.redirect(original)
+	if !ok {
+		x.report(entry, target, ActionSkipped)
+		return nil
+	}
+
+	switch entry.Type {
+	case EntrySymlink:
+		if err := checkSymlinkTarget(entry.Name, entry.Linkname); err != nil {
+			return &UnsafeEntryError{Name: entry.Name, Linkname: entry.Linkname, Err: err}
+		}
+	case EntryHardlink:
+		if _, err := safeJoin(x.dest, entry.Linkname); err != nil {
+			return &UnsafeEntryError{Name: entry.Name, Linkname: entry.Linkname, Err: ErrLinkEscape}
+		}
+	}
+
+	if entry.Type == EntryOther ||
+		(entry.Type == EntrySymlink && x.opts.SkipSymlinks) ||
+		(entry.Type == EntryHardlink && x.opts.SkipHardlinks) {
+		x.report(entry, target, ActionSkipped)
+		return nil
+	}
+
+	if err := x.checkTarget(entry.Name, target); err != nil {
+		return err
+	}
+	if !x.opts.NoCreateParents {
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+	}
+
+	if entry.Type == EntryDir {
+		return x.extractDir(entry, original, target)
+	}
+
+	target, action, err := x.resolveConflict(target)
+	if err != nil || action == ActionSkipped {
+		if err == nil {
+			x.report(entry, target, action)
+		}
+		return err
+	}
+
+	switch entry.Type {
+	case EntrySymlink:
+		// The text of the link target was checked before, now follow the links already on disk
+		if err := checkSymlinkResolved(x.dest, target, entry.Linkname); err != nil {
+			if err == ErrLinkEscape {
+				return &UnsafeEntryError{Name: entry.Name, Linkname: entry.Linkname, Err: err}
+			}
+			return err
+		}
+		if err := os.Symlink(entry.Linkname, target); err != nil {
+			return err
+		}
+		if x.opts.PreserveOwner {
+			if err := os.Lchown(target, entry.Uid, entry.Gid); err != nil {
+				return err
+			}
+		}
+
+	case EntryHardlink:
+		source, _ := safeJoin(x.dest, entry.Linkname)
+		source, _ = x.redirect(source)
+		if err := x.checkTarget(entry.Name, source); err != nil {
+			return err
+		}
+		if err := os.Link(source, target); err != nil {
+			return err
+		}
+
+	default:
+		if err := x.writeFile(entry, target, content); err != nil {
+			return err
+		}
+	}
+
+	x.report(entry, target, action)
+	return nil
+}
+
+// finish applies modes and modification times to the extracted directories, deepest first,
+// because extracting files into a directory changes its modification time.
+func (x *extractor) finish() error {
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		if err := x.applyMeta(x.dirs[i].target, x.dirs[i].entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTarget makes sure no existing symlink redirects target outside of dest
+func (x *extractor) checkTarget(name, target string) error {
+	err := checkNoSymlinkEscape(x.dest, target)
+	if err == ErrLinkEscape {
+		return &UnsafeEntryError{Name: name, Err: err}
+	}
+	return err
+}
+
+// extractDir creates a directory entry or merges it into an existing directory. original is the
+// path of the entry before it was redirected into a moved parent directory.
+func (x *extractor) extractDir(entry ArchiveEntry, original, target string) error {
+	action := ActionCreated
+	info, err := os.Lstat(target)
+	switch {
+	case err == nil && info.IsDir():
+		action = ActionMerged
+	case err == nil && x.opts.Overwrite == ConflictOverwrite:
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+		action = ActionOverwritten
+	case err == nil && x.opts.Overwrite == ConflictSkip:
+		// The content of the directory is skipped as well
+		x.moved[original] = ""
+		x.report(entry, target, ActionSkipped)
+		return nil
+	case err == nil && x.opts.Overwrite == ConflictRename:
+		renamed, err := uniquePath(target)
+		if err != nil {
+			return err
+		}
+		x.moved[original] = renamed
+		target, action = renamed, ActionRenamed
+	case err == nil:
+		return &os.PathError{Op: "extract", Path: target, Err: os.ErrExist}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if action != ActionMerged {
+		if err := os.Mkdir(target, 0755); err != nil {
+			return err
+		}
+	}
+
+	x.dirs = append(x.dirs, dirMeta{target: target, entry: entry})
+	x.report(entry, target, action)
+	return nil
+}
+
+// redirect returns the path an entry at target is written to, if one of its parent directories was
+// renamed by the conflict policy, and false if the parent was skipped
+func (x *extractor) redirect(target string) (string, bool) {
+	if len(x.moved) == 0 {
+		return target, true
+	}
+	for dir := filepath.Dir(target); len(dir) > len(x.dest); dir = filepath.Dir(dir) {
+		if to, ok := x.moved[dir]; ok {
+			if to == "" {
+				return target, false
+			}
+			return to + target[len(dir):], true
+		}
+	}
+	return target, true
+}
+
+// resolveConflict applies the overwrite policy to target and returns the path to write to
+func (x *extractor) resolveConflict(target string) (string, EntryAction, error) {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return target, ActionCreated, nil
+	}
+	if err != nil {
+		return target, ActionCreated, err
+	}
+
+	switch x.opts.Overwrite {
+	case ConflictSkip:
+		return target, ActionSkipped, nil
+	case ConflictFail:
+		return target, ActionCreated, &os.PathError{Op: "extract", Path: target, Err: os.ErrExist}
+	case ConflictRename:
+		renamed, err := uniquePath(target)
+		return renamed, ActionRenamed, err
+	}
+
+	if info.IsDir() {
+		return target, ActionCreated, fmt.Errorf("extract %s: cannot replace a directory", target)
+	}
+	// Remove the old file instead of truncating it, so existing symlinks and hard links are not followed
+	if err := os.Remove(target); err != nil {
+		return target, ActionCreated, err
+	}
+	return target, ActionOverwritten, nil
+}
+
+// writeFile writes the content of a regular file entry to the new file target
+func (x *extractor) writeFile(entry ArchiveEntry, target string, content io.Reader) (retErr error) {
+	perm := os.FileMode(0644)
+	if !x.opts.SkipPermissions {
+		perm = entry.Mode.Perm()
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := file.Close()
+		if closeErr != nil && retErr == nil {
+			retErr = closeErr
+		}
+		if retErr == nil {
+			retErr = x.applyMeta(target, entry)
+		}
+	}()
+
+	_, err = x.guard.copy(entry.Name, file, content)
+	return err
+}
+
+// applyMeta restores mode, ownership and modification time of target as configured
+func (x *extractor) applyMeta(target string, entry ArchiveEntry) error {
+	if x.opts.PreserveOwner {
+		if err := os.Lchown(target, entry.Uid, entry.Gid); err != nil {
+			return err
+		}
+	}
+
+	if !x.opts.SkipPermissions {
+		// Archives from Windows tools carry 0666 and 0777, so the umask is applied like tar does
+		mode := entry.Mode.Perm() &^ umask
+		if x.opts.PreserveOwner {
+			mode |= entry.Mode & (os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		}
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+	}
+
+	if !x.opts.SkipMtime && !entry.ModTime.IsZero() {
+		if err := os.Chtimes(target, entry.ModTime, entry.ModTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// report passes the result of an entry to the OnEntry callback, if there is one
+func (x *extractor) report(entry ArchiveEntry, target string, action EntryAction) {
+	if x.opts.OnEntry != nil {
+		x.opts.OnEntry(entry, target, action)
+	}
+}
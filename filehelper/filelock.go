@@ -0,0 +1,224 @@
+package filehelper
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when a lock is held by another process (or another FileLock of this process)
+var ErrLocked = errors.New("file is locked")
+
+// lockRetryInterval is the pause between the attempts of LockTimeout
+const lockRetryInterval = 50 * time.Millisecond
+
+// FileLock is an exclusive advisory lock (flock) on a file. The lock is bound to the open file, so the
+// kernel releases it when the process exits, also after a crash. Only processes using flock on the
+// same file are excluded, reading and writing the file is not prevented.
+type FileLock struct {
+	file *os.File
+}
+
+// Lock creates the file at path if necessary and locks it, waiting as long as another process holds the lock
+func Lock(path string) (*FileLock, error) {
+	return lockFile(path, unix.LOCK_EX)
+}
+
+// TryLock works like Lock, but returns ErrLocked at once if another process holds the lock
+func TryLock(path string) (*FileLock, error) {
+	return lockFile(path, unix.LOCK_EX|unix.LOCK_NB)
+}
+
+// LockTimeout works like Lock, but returns ErrLocked if the lock cannot be acquired within timeout
+func LockTimeout(path string, timeout time.Duration) (*FileLock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		lock, err := TryLock(path)
+		if !errors.Is(err, ErrLocked) || !time.Now().Before(deadline) {
+			return lock, err
+		}
+		pause := lockRetryInterval
+		if remaining := time.Until(deadline); remaining < pause {
+			pause = remaining
+		}
+		time.Sleep(pause)
+	}
+}
+
+// lockFile opens the file at path and applies flock with how
+func lockFile(path string, how int) (*FileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err = unix.Flock(int(file.Fd()), how)
+		if !errors.Is(err, unix.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		_ = file.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+
+	return &FileLock{file: file}, nil
+}
+
+// Path returns the path of the locked file
+func (l *FileLock) Path() string {
+	return l.file.Name()
+}
+
+// File returns the locked file, e.g. to read or write its content while holding the lock
+func (l *FileLock) File() *os.File {
+	return l.file
+}
+
+// Unlock releases the lock and closes the file. The file itself is kept.
+func (l *FileLock) Unlock() error {
+	err := unix.Flock(int(l.file.Fd()), unix.LOCK_UN)
+	return errors.Join(err, l.file.Close())
+}
+
+// InstanceInfo is the content of a PID lockfile written by SingleInstance
+type InstanceInfo struct {
+	PID     int
+	Started time.Time
+}
+
+// AlreadyRunningError is returned by SingleInstance if another instance holds the lock
+type AlreadyRunningError struct {
+	Path string
+	Info InstanceInfo // zero if the lockfile could not be read
+}
+
+func (e *AlreadyRunningError) Error() string {
+	if e.Info.PID == 0 {
+		return fmt.Sprintf("another instance holds the lock %s", e.Path)
+	}
+	return fmt.Sprintf("another instance (pid %d, started %s) holds the lock %s",
+		e.Info.PID, e.Info.Started.Format(time.RFC3339), e.Path)
+}
+
+// Unwrap makes errors.Is(err, ErrLocked) true
+func (e *AlreadyRunningError) Unwrap() error {
+	return ErrLocked
+}
+
+// InstanceLock is held by the single running instance of a program, see SingleInstance
+type InstanceLock struct {
+	lock *FileLock
+	path string
+
+	// Stale is the content of a lockfile left behind by an instance which ended without Release,
+	// or nil if there was none
+	Stale *InstanceInfo
+}
+
+// SingleInstance makes sure only one instance of a program (e.g. a cron job) runs at a time. It
+// locks the file at lockPath and writes the PID and start time of this process into it. If another
+// instance holds the lock, an *AlreadyRunningError (matching ErrLocked) with its PID is returned.
+//
+// The lock is released by Release, which should be deferred, or by the kernel when the process exits.
+// A lockfile left behind by an instance which ended without Release (e.g. after a crash) is taken
+// over and reported in InstanceLock.Stale. On file systems without flock support, the lockfile is
+// considered stale once no process with its PID is running.
+//
+// Example:
+//
+//	instance, err := SingleInstance("/var/run/myjob.lock")
+//	if errors.Is(err, ErrLocked) {
+//		return // previous run still active
+//	}
+//	defer instance.Release()
+func SingleInstance(lockPath string) (*InstanceLock, error) {
+	lock, err := TryLock(lockPath)
+	previous, readErr := readInstanceInfo(lockPath)
+	if errors.Is(err, ErrLocked) {
+		return nil, &AlreadyRunningError{Path: lockPath, Info: previous}
+	}
+	if err != nil && !errors.Is(err, unix.ENOLCK) && !errors.Is(err, unix.EOPNOTSUPP) {
+		return nil, err
+	}
+	if err != nil {
+		// No flock support, rely on the PID
+		if readErr == nil && processAlive(previous.PID) {
+			return nil, &AlreadyRunningError{Path: lockPath, Info: previous}
+		}
+	}
+
+	instance := &InstanceLock{lock: lock, path: lockPath}
+	if readErr == nil && previous.PID != 0 {
+		instance.Stale = &previous
+	}
+
+	info := InstanceInfo{PID: os.Getpid(), Started: time.Now()}
+	content := []byte(fmt.Sprintf("%d\n%s\n", info.PID, info.Started.Format(time.RFC3339)))
+	if lock != nil {
+		// Write in place: replacing the file would detach the lock from the path
+		file := lock.File()
+		err := file.Truncate(0)
+		if err == nil {
+			_, err = file.WriteAt(content, 0)
+		}
+		if err == nil {
+			err = file.Sync()
+		}
+		if err != nil {
+			_ = lock.Unlock()
+			return nil, err
+		}
+	} else if err := WriteFileAtomic(lockPath, content, 0644); err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// Release empties the lockfile and releases the lock
+func (i *InstanceLock) Release() error {
+	if i.lock == nil {
+		return os.Remove(i.path)
+	}
+	err := i.lock.File().Truncate(0)
+	return errors.Join(err, i.lock.Unlock())
+}
+
+// readInstanceInfo reads the PID and start time from a lockfile. An empty file gives a zero InstanceInfo.
+func readInstanceInfo(path string) (InstanceInfo, error) {
+	var info InstanceInfo
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return info, nil
+	}
+	if info.PID, err = strconv.Atoi(fields[0]); err != nil {
+		return info, err
+	}
+	if len(fields) > 1 {
+		info.Started, _ = time.Parse(time.RFC3339, fields[1])
+	}
+	return info, nil
+}
+
+// processAlive reports whether a process with the PID exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := unix.Kill(pid, 0)
+	return err == nil || errors.Is(err, unix.EPERM)
+}
@@ -0,0 +1,192 @@
+package filehelper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.lock")
+
+	first, err := TryLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flock is bound to the open file, so a second lock fails within the same process as well
+	if second, err := TryLock(path); !errors.Is(err, ErrLocked) || second != nil {
+		t.Errorf("got %v : %v, want %v", second, err, ErrLocked)
+	}
+	if err := first.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	third, err := TryLock(path)
+	if err != nil {
+		t.Fatalf("got %v after unlock", err)
+	}
+	if third.Path() != path {
+		t.Errorf("got path %s, want %s", third.Path(), path)
+	}
+	if err := third.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		release time.Duration // time after which the holder releases the lock, 0 never
+		timeout time.Duration
+		wantErr error
+	}{
+		// the table itself
+		{"POS released in time", 100 * time.Millisecond, 2 * time.Second, nil},
+		{"NEG timeout", 0, 200 * time.Millisecond, ErrLocked},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "job.lock")
+			holder, err := Lock(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.release > 0 {
+				time.AfterFunc(tt.release, func() {
+					_ = holder.Unlock()
+				})
+			} else {
+				defer func(holder *FileLock) {
+					_ = holder.Unlock()
+				}(holder)
+			}
+
+			start := time.Now()
+			lock, err := LockTimeout(path, tt.timeout)
+			elapsed := time.Since(start)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if elapsed < tt.timeout {
+					t.Errorf("gave up after %s, want %s", elapsed, tt.timeout)
+				}
+				return
+			}
+			if elapsed < tt.release || elapsed >= tt.timeout {
+				t.Errorf("got the lock after %s", elapsed)
+			}
+			if err := lock.Unlock(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestLockWaits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.lock")
+	holder, err := Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan *FileLock)
+	go func() {
+		lock, err := Lock(path)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- lock
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("got the lock while it is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case lock := <-acquired:
+		if lock != nil {
+			_ = lock.Unlock()
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("got no lock after release")
+	}
+}
+
+func TestSingleInstance(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		content string // content of the lockfile before, empty for none
+		running bool   // another instance holds the lock
+		stale   int    // PID of the stale instance reported, 0 for none
+	}{
+		// the table itself
+		{"POS no lockfile", "", false, 0},
+		{"POS stale lockfile", "999999\n2024-06-30T12:00:00Z\n", false, 999999},
+		{"POS released lockfile", "\n", false, 0},
+		{"NEG running", "", true, 0},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "job.lock")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.running {
+				other, err := SingleInstance(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func(other *InstanceLock) {
+					_ = other.Release()
+				}(other)
+			}
+
+			instance, err := SingleInstance(path)
+			if tt.running {
+				var running *AlreadyRunningError
+				if !errors.As(err, &running) || !errors.Is(err, ErrLocked) {
+					t.Fatalf("got %v, want an AlreadyRunningError", err)
+				}
+				if running.Info.PID != os.Getpid() || running.Info.Started.IsZero() {
+					t.Errorf("got %+v, want this process", running.Info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case tt.stale == 0 && instance.Stale != nil:
+				t.Errorf("got stale %+v, want none", instance.Stale)
+			case tt.stale != 0 && (instance.Stale == nil || instance.Stale.PID != tt.stale):
+				t.Errorf("got stale %+v, want pid %d", instance.Stale, tt.stale)
+			}
+			if info, err := readInstanceInfo(path); err != nil || info.PID != os.Getpid() {
+				t.Errorf("got %+v : %v, want this process", info, err)
+			}
+
+			if err := instance.Release(); err != nil {
+				t.Fatal(err)
+			}
+			if info, err := readInstanceInfo(path); err != nil || info.PID != 0 {
+				t.Errorf("got %+v : %v after release, want an empty lockfile", info, err)
+			}
+		})
+	}
+}
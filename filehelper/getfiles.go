@@ -2,13 +2,18 @@ package filehelper
 
 import (
 	"os"
-	"path/filepath"
+	"time"
 )
 
 // FileInfo represents information about a file.
 type FileInfo struct {
-	Path string
-	Name string
+	Path    string      // path of the file, starting with the scanned directory
+	Name    string      // base name of the file
+	RelPath string      // path relative to the scanned directory, using the OS separator
+	Depth   int         // number of directories between the scanned directory and the file, 0 for top-level files
+	Size    int64       // size in bytes
+	Mode    os.FileMode // file mode bits
+	ModTime time.Time   // modification time
 }
 
 // GetFiles scans the provided directory and returns files that match the provided pattern.
@@ -31,26 +36,9 @@ type FileInfo struct {
 //
 // The returned Path in each FileInfo is combining the directory path and the file name.
 // The Name in each FileInfo is the name of the file (not including the path).
+// Use ListFiles for more filter options.
 func GetFiles(directory, pattern string) ([]FileInfo, error) {
-	var files []FileInfo
-
-	fileInfo, err := os.ReadDir(directory)
-
-	if err != nil {
-		return nil, err
-	}
-
-	for _, info := range fileInfo {
-		if !info.IsDir() {
-
-			if match, _ := filepath.Match(pattern, info.Name()); match {
-				files = append(files, FileInfo{Path: filepath.Join(directory, info.Name()), Name: info.Name()})
-			}
-
-		}
-	}
-
-	return files, nil
+	return ListFiles(directory, ListOptions{Patterns: []string{pattern}})
 }
 
 // GetFilesRecursive is a function that traverses a provided directory recursively
@@ -62,6 +50,7 @@ func GetFiles(directory, pattern string) ([]FileInfo, error) {
 //
 // The function returns a list of FileInfo objects and an error object. FileInfo object includes the full path and the name of the file.
 // The error object will be non-nil if there was an error during the function's execution.
+// Use ListFiles for more filter options.
 //
 // Usage:
 //
@@ -75,25 +64,5 @@ func GetFiles(directory, pattern string) ([]FileInfo, error) {
 //	    fmt.Println(file.Path)
 //	}
 func GetFilesRecursive(directory, pattern string) ([]FileInfo, error) {
-	var files []FileInfo
-
-	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			if match, _ := filepath.Match(pattern, info.Name()); match {
-				files = append(files, FileInfo{Path: filepath.Join(directory, info.Name()), Name: info.Name()})
-			}
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return files, nil
+	return ListFiles(directory, ListOptions{Patterns: []string{pattern}, MaxDepth: UnlimitedDepth})
 }
@@ -1,15 +1,28 @@
 package filehelper
 
 import (
+	"bufio"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // GzipFile compresses a file to a gzip file.
 // It takes the file at 'FilePath' and compresses it to a gzip file at 'outputFilePath'.
+// The base name and the modification time of the original file are recorded in the gzip header.
+// The gzip file is written atomically (see AtomicWriter), so it never exists with partial content.
 // If there is an error during the process, it will be returned. No error means successful compression.
 func GzipFile(FilePath string, outputFilePath string) error {
+	return GzipFileWithOptions(FilePath, outputFilePath, GzipOptions{})
+}
+
+// GzipFileWithOptions works like GzipFile, but uses the compression level of opts and,
+// if opts.Parallel is set, compresses blocks of the file on several goroutines.
+// The header name and modification time are taken from the original file unless set in opts.
+func GzipFileWithOptions(FilePath string, outputFilePath string, opts GzipOptions) error {
 	// Open the file for reading
 	tarFile, err := os.Open(FilePath)
 	if err != nil {
@@ -19,18 +32,31 @@ func GzipFile(FilePath string, outputFilePath string) error {
 		_ = tarFile.Close()
 	}(tarFile)
 
-	// Create the output file for writing
-	outputFile, err := os.Create(outputFilePath)
+	// Record the original name and modification time in the gzip header
+	info, err := tarFile.Stat()
+	if err != nil {
+		return err
+	}
+	if opts.Name == "" {
+		opts.Name = filepath.Base(FilePath)
+	}
+	if opts.ModTime.IsZero() {
+		opts.ModTime = info.ModTime()
+	}
+
+	// Create the output file for writing, it only appears once it is complete
+	outputFile, err := NewAtomicWriter(outputFilePath, 0644)
 	if err != nil {
 		return err
 	}
-	defer func(outputFile *os.File) {
-		_ = outputFile.Close()
-	}(outputFile)
+	defer outputFile.Abort()
 
 	// Create a new gzip writer
-	gzipWriter := gzip.NewWriter(outputFile)
-	defer func(gzipWriter *gzip.Writer) {
+	gzipWriter, err := NewGzipWriter(outputFile, opts)
+	if err != nil {
+		return err
+	}
+	defer func(gzipWriter io.WriteCloser) {
 		_ = gzipWriter.Close()
 	}(gzipWriter)
 
@@ -46,44 +72,105 @@ func GzipFile(FilePath string, outputFilePath string) error {
 		return err
 	}
 
-	return nil
+	return outputFile.Close()
+}
+
+// UnzipOptions controls how UnzipFileWithOptions names and stamps the decompressed file
+type UnzipOptions struct {
+	UseHeaderName  bool // treat the output path as directory and name the file after the gzip header
+	RestoreModTime bool // set the modification time of the output file from the gzip header
 }
 
 // UnzipFile decompresses a gzip file located at inputFilePath to an
 // output file at outputFilePath. It returns an error if any issue is encountered.
+// The format is detected from the magic bytes of the file, so zstd, bzip2 and xz
+// compressed files are decompressed as well, see Decompress.
+// The output file is written atomically (see AtomicWriter), so it never exists with partial content.
 func UnzipFile(inputFilePath string, outputFilePath string) error {
-	// Open the gzip file
+	_, err := UnzipFileWithOptions(inputFilePath, outputFilePath, UnzipOptions{})
+	return err
+}
+
+// UnzipFileWithOptions works like UnzipFile, but can restore the original file name and
+// modification time recorded in the gzip header by GzipFile.
+//
+// With opts.UseHeaderName, outputFilePath is a directory and the file is written there under the
+// base name stored in the header. If the header holds no usable name (or the file is not gzip),
+// the input file name without its compression extension is used instead.
+//
+// It returns the path of the written file and an error if it would be the input file itself.
+func UnzipFileWithOptions(inputFilePath string, outputFilePath string, opts UnzipOptions) (string, error) {
+	// Open the compressed file
 	inputFile, err := os.Open(inputFilePath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer func(inputFile *os.File) {
 		_ = inputFile.Close()
 	}(inputFile)
 
-	// Create a new gzip reader
-	gzipReader, err := gzip.NewReader(inputFile)
+	// Create a new decompressing reader, keeping the gzip header if there is one
+	br := bufio.NewReader(inputFile)
+	codec, err := DetectCodec(br)
 	if err != nil {
-		return err
+		return "", err
+	}
+	var header gzip.Header
+	var reader io.ReadCloser
+	if codec == Gzip {
+		gzipReader, err := gzip.NewReader(br)
+		if err != nil {
+			return "", err
+		}
+		header, reader = gzipReader.Header, gzipReader
+	} else if reader, err = codec.NewReader(br); err != nil {
+		return "", err
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(reader)
+
+	if opts.UseHeaderName {
+		name := filepath.Base(filepath.FromSlash(header.Name))
+		if header.Name == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+			name = strings.TrimSuffix(filepath.Base(inputFilePath), codec.Extension())
+			name = strings.TrimSuffix(name, strings.ToUpper(codec.Extension()))
+		}
+		outputFilePath = filepath.Join(outputFilePath, name)
 	}
-	defer func(gzipReader *gzip.Reader) {
-		_ = gzipReader.Close()
-	}(gzipReader)
 
-	// Create the output file
-	outputFile, err := os.Create(outputFilePath)
+	// Writing the output over the input file would replace the compressed original by its content,
+	// e.g. if the name in the gzip header is the name of the input file itself
+	inputInfo, err := inputFile.Stat()
 	if err != nil {
-		return err
+		return "", err
+	}
+	if outputInfo, err := os.Stat(outputFilePath); err == nil && os.SameFile(inputInfo, outputInfo) {
+		return "", fmt.Errorf("output file %s is the input file", outputFilePath)
 	}
-	defer func(outputFile *os.File) {
-		_ = outputFile.Close()
-	}(outputFile)
 
-	// Copy the gzip content to the output file
-	_, err = io.Copy(outputFile, gzipReader)
+	// Create the output file, it only appears once it is complete
+	outputFile, err := NewAtomicWriter(outputFilePath, 0644)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer outputFile.Abort()
+
+	// Copy the decompressed content to the output file
+	_, err = io.Copy(outputFile, reader)
+	if err != nil {
+		return "", err
+	}
+
+	if err := outputFile.Close(); err != nil {
+		return "", err
+	}
+
+	if opts.RestoreModTime && !header.ModTime.IsZero() {
+		if err := os.Chtimes(outputFilePath, header.ModTime, header.ModTime); err != nil {
+			return "", err
+		}
 	}
 
-	return nil
+	return outputFilePath, nil
 }
@@ -0,0 +1,90 @@
+package filehelper
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeGzip writes content to path as gzip file with the given header name
+func writeGzip(t *testing.T, path, headerName string, modTime time.Time, content []byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	gw := gzip.NewWriter(f)
+	gw.Name, gw.ModTime = headerName, modTime
+	if _, err := gw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnzipFileWithOptions(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name   string
+		file   string // name of the gzip file in the test directory
+		header string // file name in the gzip header
+		output string // output path relative to the test directory, a directory with UseHeaderName
+		opts   UnzipOptions
+		want   string // path of the written file relative to the test directory, empty if an error is expected
+	}{
+		// the table itself
+		{"POS plain", "data.csv.gz", "data.csv", "out.csv", UnzipOptions{}, "out.csv"},
+		{"POS header name", "upload.gz", "data.csv", ".", UnzipOptions{UseHeaderName: true}, "data.csv"},
+		{"POS header name with path", "upload.gz", "../../etc/data.csv", ".", UnzipOptions{UseHeaderName: true}, "data.csv"},
+		{"POS no header name", "data.csv.gz", "", ".", UnzipOptions{UseHeaderName: true}, "data.csv"},
+		{"POS restore modification time", "data.csv.gz", "data.csv", "out.csv", UnzipOptions{RestoreModTime: true}, "out.csv"},
+		{"NEG output is input", "data.csv.gz", "data.csv", "data.csv.gz", UnzipOptions{}, ""},
+		{"NEG header name is input", "data.csv", "data.csv", ".", UnzipOptions{UseHeaderName: true}, ""},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, tt.file)
+			writeGzip(t, input, tt.header, modTime, []byte("content"))
+
+			got, err := UnzipFileWithOptions(input, filepath.Join(dir, tt.output), tt.opts)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("got %s, want an error", got)
+				}
+				// The input file must still be intact
+				if _, err := VerifyGzip(input); err != nil {
+					t.Errorf("input damaged: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+			content, err := os.ReadFile(got)
+			if err != nil || string(content) != "content" {
+				t.Errorf("got %q : %v", content, err)
+			}
+			info, err := os.Stat(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored := info.ModTime().Equal(modTime); restored != tt.opts.RestoreModTime {
+				t.Errorf("got modification time %s, restore %t", info.ModTime(), tt.opts.RestoreModTime)
+			}
+		})
+	}
+}
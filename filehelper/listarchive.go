@@ -0,0 +1,206 @@
+package filehelper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrEntryNotFound is returned by ExtractEntry when the archive has no file of the requested name
+var ErrEntryNotFound = errors.New("entry not found in archive")
+
+// zipMagic are the first bytes of a ZIP archive, or of an empty one
+var zipMagic = [][]byte{[]byte("PK\x03\x04"), []byte("PK\x05\x06")}
+
+// ListArchive returns the entries of the archive at archivePath without extracting anything.
+// ZIP archives and tar archives (uncompressed or compressed with gzip, zstd, bzip2 or xz) are
+// recognized by their content, so the file extension does not matter.
+func ListArchive(archivePath string) ([]ArchiveEntry, error) {
+	var entries []ArchiveEntry
+	err := WalkArchive(archivePath, func(entry ArchiveEntry, r io.Reader) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WalkArchive calls fn for every entry of the archive at archivePath, in archive order.
+// For regular files, r streams the content of the entry and is only valid during the call;
+// for other entries it is empty. The archive formats are the same as for ListArchive.
+// Returning an error from fn stops the walk and returns that error.
+func WalkArchive(archivePath string, fn func(entry ArchiveEntry, r io.Reader) error) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	br := bufio.NewReader(f)
+	header, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	for _, magic := range zipMagic {
+		if bytes.Equal(header, magic) {
+			info, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			return walkZip(f, info.Size(), fn)
+		}
+	}
+
+	return walkTar(br, fn)
+}
+
+// ExtractEntry writes the content of the regular file called name in the archive at archivePath to w.
+// Leading "./" and redundant slashes are ignored when comparing names. If there is no such file,
+// ErrEntryNotFound is returned. Reading stops as soon as the entry has been written.
+//
+// Usage:
+//
+//	var manifest bytes.Buffer
+//	err := ExtractEntry("/data/inbox/delivery.tar.gz", "manifest.json", &manifest)
+func ExtractEntry(archivePath, name string, w io.Writer) error {
+	want := cleanArchiveName(name)
+	errFound := errors.New("found")
+
+	err := WalkArchive(archivePath, func(entry ArchiveEntry, r io.Reader) error {
+		if entry.Type != EntryFile || cleanArchiveName(entry.Name) != want {
+			return nil
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+		return errFound
+	})
+
+	switch err {
+	case errFound:
+		return nil
+	case nil:
+		return fmt.Errorf("%s: %w", name, ErrEntryNotFound)
+	default:
+		return err
+	}
+}
+
+// ExtractMatchingEntries writes the content of all regular files in the archive at archivePath
+// whose name matches pattern to w, one after another in archive order, and returns their number.
+// The pattern uses filepath.Match syntax and is matched against the base name of an entry, or
+// against its full slash-separated name if the pattern contains a '/'.
+func ExtractMatchingEntries(archivePath, pattern string, w io.Writer) (int, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, err
+	}
+
+	matched := 0
+	err := WalkArchive(archivePath, func(entry ArchiveEntry, r io.Reader) error {
+		name := cleanArchiveName(entry.Name)
+		if entry.Type != EntryFile || !matchAnyGlob([]string{pattern}, path.Base(name), name) {
+			return nil
+		}
+		matched++
+		_, err := io.Copy(w, r)
+		return err
+	})
+
+	return matched, err
+}
+
+// cleanArchiveName normalizes an entry name for comparisons
+func cleanArchiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// walkTar calls fn for every entry of a possibly compressed tar stream
+func walkTar(br *bufio.Reader, fn func(entry ArchiveEntry, r io.Reader) error) error {
+	var r io.Reader = br
+	codec, err := DetectCodec(br)
+	if err == nil {
+		rc, err := codec.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer func(rc io.ReadCloser) {
+			_ = rc.Close()
+		}(rc)
+		r = rc
+	} else if err != ErrUnknownFormat {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entry := tarEntry(header)
+		var content io.Reader = tr
+		if entry.Type != EntryFile {
+			content = bytes.NewReader(nil)
+		}
+		if err := fn(entry, content); err != nil {
+			return err
+		}
+	}
+}
+
+// walkZip calls fn for every entry of a ZIP archive
+func walkZip(r io.ReaderAt, size int64, fn func(entry ArchiveEntry, r io.Reader) error) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil && err != zip.ErrInsecurePath {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if err := walkZipEntry(f, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkZipEntry calls fn for a single ZIP member
+func walkZipEntry(f *zip.File, fn func(entry ArchiveEntry, r io.Reader) error) error {
+	entry := zipEntry(&f.FileHeader)
+	if entry.Type != EntryFile && entry.Type != EntrySymlink {
+		return fn(entry, bytes.NewReader(nil))
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func(rc io.ReadCloser) {
+		_ = rc.Close()
+	}(rc)
+
+	if entry.Type == EntrySymlink {
+		link, err := io.ReadAll(io.LimitReader(rc, maxZipLinkSize))
+		if err != nil {
+			return err
+		}
+		entry.Linkname = string(link)
+		return fn(entry, bytes.NewReader(nil))
+	}
+
+	return fn(entry, rc)
+}
@@ -0,0 +1,185 @@
+package filehelper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// archiveTestEntries is the content of the archives built by writeTestArchives
+var archiveTestEntries = []tarGzEntry{
+	{name: "data/", typeflag: tar.TypeDir},
+	{name: "data/a.csv", typeflag: tar.TypeReg, content: []byte("a")},
+	{name: "data/b.txt", typeflag: tar.TypeReg, content: []byte("bb")},
+	{name: "c.csv", typeflag: tar.TypeReg, content: []byte("ccc")},
+}
+
+// writeTestArchives writes archiveTestEntries as tar, tar.gz and zip archive and returns their paths by format
+func writeTestArchives(t *testing.T) map[string]string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := map[string]string{
+		"tar":    filepath.Join(dir, "test.tar"),
+		"tar.gz": filepath.Join(dir, "test.tar.gz"),
+		"zip":    filepath.Join(dir, "test.zip"),
+	}
+
+	targz := buildTarGz(t, archiveTestEntries)
+	var tarData bytes.Buffer
+	if err := Decompress(bytes.NewReader(targz), &tarData); err != nil {
+		t.Fatal(err)
+	}
+
+	var zipData bytes.Buffer
+	zw := zip.NewWriter(&zipData)
+	for _, e := range archiveTestEntries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(e.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for format, data := range map[string][]byte{"tar": tarData.Bytes(), "tar.gz": targz, "zip": zipData.Bytes()} {
+		if err := os.WriteFile(paths[format], data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func TestListArchive(t *testing.T) {
+	for format, archive := range writeTestArchives(t) {
+		t.Run(format, func(t *testing.T) {
+			entries, err := ListArchive(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			var types []EntryType
+			for _, entry := range entries {
+				names = append(names, cleanArchiveName(entry.Name))
+				types = append(types, entry.Type)
+			}
+			if want := []string{"data", "data/a.csv", "data/b.txt", "c.csv"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("got %v, want %v", names, want)
+			}
+			if want := []EntryType{EntryDir, EntryFile, EntryFile, EntryFile}; !reflect.DeepEqual(types, want) {
+				t.Errorf("got %v, want %v", types, want)
+			}
+			if entries[3].Size != 3 {
+				t.Errorf("got size %d, want 3", entries[3].Size)
+			}
+		})
+	}
+}
+
+func TestWalkArchive(t *testing.T) {
+	errStop := errors.New("stop")
+	for format, archive := range writeTestArchives(t) {
+		t.Run(format, func(t *testing.T) {
+			var got []string
+			err := WalkArchive(archive, func(entry ArchiveEntry, r io.Reader) error {
+				content, err := io.ReadAll(r)
+				if err != nil {
+					return err
+				}
+				got = append(got, string(content))
+				if entry.Name == "data/b.txt" {
+					return errStop
+				}
+				return nil
+			})
+			if !errors.Is(err, errStop) {
+				t.Errorf("got %v, want %v", err, errStop)
+			}
+			if want := []string{"", "a", "bb"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestExtractEntry(t *testing.T) {
+	archives := writeTestArchives(t)
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		entry   string
+		want    string
+		wantErr error
+	}{
+		// the table itself
+		{"POS file", "data/a.csv", "a", nil},
+		{"POS top level", "c.csv", "ccc", nil},
+		{"POS unclean name", "./data//b.txt", "bb", nil},
+		{"NEG missing", "data/missing.csv", "", ErrEntryNotFound},
+		{"NEG directory", "data", "", ErrEntryNotFound},
+		{"NEG base name only", "a.csv", "", ErrEntryNotFound},
+	}
+
+	// The execution loop
+	for format, archive := range archives {
+		for _, tt := range tests {
+			t.Run(format+" "+tt.name, func(t *testing.T) {
+				var got bytes.Buffer
+				err := ExtractEntry(archive, tt.entry, &got)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				if got.String() != tt.want {
+					t.Errorf("got %q, want %q", got.String(), tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestExtractMatchingEntries(t *testing.T) {
+	archives := writeTestArchives(t)
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		pattern string
+		count   int
+		want    string
+		wantErr error
+	}{
+		// the table itself
+		{"POS base name glob", "*.csv", 2, "accc", nil},
+		{"POS path glob", "data/*", 2, "abb", nil},
+		{"POS exact name", "b.txt", 1, "bb", nil},
+		{"POS no match", "*.xml", 0, "", nil},
+		{"NEG invalid pattern", "[", 0, "", path.ErrBadPattern},
+	}
+
+	// The execution loop
+	for format, archive := range archives {
+		for _, tt := range tests {
+			t.Run(format+" "+tt.name, func(t *testing.T) {
+				var got bytes.Buffer
+				count, err := ExtractMatchingEntries(archive, tt.pattern, &got)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				if count != tt.count || got.String() != tt.want {
+					t.Errorf("got %d %q, want %d %q", count, got.String(), tt.count, tt.want)
+				}
+			})
+		}
+	}
+}
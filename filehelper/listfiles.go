@@ -0,0 +1,210 @@
+package filehelper
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UnlimitedDepth can be used as ListOptions.MaxDepth to descend into all subdirectories
+const UnlimitedDepth = -1
+
+// SortOrder defines the order of the files returned by ListFiles
+type SortOrder int
+
+const (
+	SortNone    SortOrder = iota // keep the walk order (lexical per directory)
+	SortByName                   // by base name, then by path
+	SortByPath                   // by relative path
+	SortByMtime                  // by modification time, oldest first
+	SortBySize                   // by size, smallest first
+)
+
+// ListOptions holds the filters used by ListFiles. The zero value lists all
+// files in the top-level directory.
+type ListOptions struct {
+	Patterns   []string         // glob patterns (filepath.Match syntax), a file has to match at least one; empty matches all
+	Regexps    []*regexp.Regexp // regular expressions, a file has to match at least one; empty matches all
+	Exclude    []string         // glob patterns of files and directories to leave out
+	MinAge     time.Duration    // only files last modified at least this long ago
+	MaxAge     time.Duration    // only files last modified at most this long ago, 0 for no limit
+	MinSize    int64            // only files with at least this many bytes
+	MaxSize    int64            // only files with at most this many bytes, 0 for no limit
+	MaxDepth   int              // 0 for the top-level directory only, UnlimitedDepth for all subdirectories
+	SkipHidden bool             // leave out files and directories whose name starts with a dot
+	Sort       SortOrder        // order of the result
+	Descending bool             // reverse the sort order
+	Now        time.Time        // reference time for the age filters, time.Now() if zero
+}
+
+// ListFiles walks the provided directory and returns all regular files and symlinks which pass the
+// filters of the options, together with their metadata.
+//
+// Glob patterns in Patterns and Exclude are matched against the base name of a file. A pattern
+// containing a '/' is matched against the slash-separated relative path instead, e.g. "2023/*.csv".
+// Regular expressions are matched against the slash-separated relative path. Excluded or hidden
+// directories are not descended into.
+//
+// An error is returned if a pattern is malformed or the directory tree cannot be read.
+// If no file passes the filters, the function returns an empty slice and nil.
+//
+// Usage:
+//
+//	files, err := ListFiles("/data/inbox", ListOptions{
+//	    Patterns: []string{"*.csv", "*.txt"},
+//	    MinAge:   5 * time.Minute,
+//	    MaxDepth: UnlimitedDepth,
+//	    Sort:     SortByMtime,
+//	})
+func ListFiles(directory string, opts ListOptions) ([]FileInfo, error) {
+	for _, pattern := range append(append([]string{}, opts.Patterns...), opts.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	// WalkDir does not descend into a symbolic link, so a linked directory is resolved first
+	root, err := filepath.EvalSymlinks(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileInfo
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		slashPath := filepath.ToSlash(relPath)
+		depth := strings.Count(slashPath, "/")
+
+		if (opts.SkipHidden && strings.HasPrefix(d.Name(), ".")) || matchAnyGlob(opts.Exclude, d.Name(), slashPath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if opts.MaxDepth != UnlimitedDepth && depth >= opts.MaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if len(opts.Patterns) > 0 && !matchAnyGlob(opts.Patterns, d.Name(), slashPath) {
+			return nil
+		}
+		if len(opts.Regexps) > 0 && !matchAnyRegexp(opts.Regexps, slashPath) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && info.Mode()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		age := now.Sub(info.ModTime())
+		if age < opts.MinAge || (opts.MaxAge > 0 && age > opts.MaxAge) {
+			return nil
+		}
+		if info.Size() < opts.MinSize || (opts.MaxSize > 0 && info.Size() > opts.MaxSize) {
+			return nil
+		}
+
+		files = append(files, FileInfo{
+			Path:    filepath.Join(directory, relPath),
+			Name:    d.Name(),
+			RelPath: relPath,
+			Depth:   depth,
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortFiles(files, opts.Sort, opts.Descending)
+
+	return files, nil
+}
+
+// matchAnyGlob reports whether any of the patterns matches the name, or the
+// slash-separated relative path for patterns containing a '/'.
+func matchAnyGlob(patterns []string, name, slashPath string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = slashPath
+		}
+		if match, _ := filepath.Match(filepath.FromSlash(pattern), filepath.FromSlash(target)); match {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyRegexp reports whether any of the expressions matches s
+func matchAnyRegexp(expressions []*regexp.Regexp, s string) bool {
+	for _, re := range expressions {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortFiles sorts files in place according to order
+func sortFiles(files []FileInfo, order SortOrder, descending bool) {
+	var less func(a, b FileInfo) bool
+	switch order {
+	case SortByName:
+		less = func(a, b FileInfo) bool {
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.RelPath < b.RelPath
+		}
+	case SortByPath:
+		less = func(a, b FileInfo) bool { return a.RelPath < b.RelPath }
+	case SortByMtime:
+		less = func(a, b FileInfo) bool { return a.ModTime.Before(b.ModTime) }
+	case SortBySize:
+		less = func(a, b FileInfo) bool { return a.Size < b.Size }
+	default:
+		if descending {
+			for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+				files[i], files[j] = files[j], files[i]
+			}
+		}
+		return
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if descending {
+			return less(files[j], files[i])
+		}
+		return less(files[i], files[j])
+	})
+}
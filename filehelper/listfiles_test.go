@@ -0,0 +1,92 @@
+package filehelper
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for _, f := range []struct {
+		path string
+		size int
+		age  time.Duration
+	}{
+		{"a.csv", 10, time.Hour},
+		{"b.txt", 20, 2 * time.Hour},
+		{".hidden.csv", 30, time.Hour},
+		{"sub/c.csv", 40, 48 * time.Hour},
+		{"sub/deep/d.csv", 50, time.Minute},
+		{"skip/e.csv", 60, time.Hour},
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, f.size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-f.age), now.Add(-f.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A symbolic link to the directory is listed like the directory itself
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name string
+		root string // directory to list, dir if empty
+		opts ListOptions
+		want []string
+	}{
+		{"top-level", "", ListOptions{}, []string{".hidden.csv", "a.csv", "b.txt"}},
+		{"pattern", "", ListOptions{Patterns: []string{"*.csv"}, SkipHidden: true}, []string{"a.csv"}},
+		{"recursive", "", ListOptions{Patterns: []string{"*.csv"}, MaxDepth: UnlimitedDepth, SkipHidden: true, Exclude: []string{"skip"}},
+			[]string{"a.csv", "sub/c.csv", "sub/deep/d.csv"}},
+		{"depth", "", ListOptions{MaxDepth: 1, Exclude: []string{".*", "*.txt"}, Sort: SortByPath}, []string{"a.csv", "skip/e.csv", "sub/c.csv"}},
+		{"regexp", "", ListOptions{Regexps: []*regexp.Regexp{regexp.MustCompile(`^sub/`)}, MaxDepth: UnlimitedDepth}, []string{"sub/c.csv", "sub/deep/d.csv"}},
+		{"age", "", ListOptions{MinAge: 30 * time.Minute, MaxAge: 24 * time.Hour, MaxDepth: UnlimitedDepth, Now: now, Sort: SortByMtime},
+			[]string{"b.txt", ".hidden.csv", "a.csv", "skip/e.csv"}},
+		{"size", "", ListOptions{MinSize: 30, MaxSize: 50, MaxDepth: UnlimitedDepth, Sort: SortBySize, Descending: true},
+			[]string{"sub/deep/d.csv", "sub/c.csv", ".hidden.csv"}},
+		{"symlinked root", link, ListOptions{Patterns: []string{"*.csv"}, MaxDepth: UnlimitedDepth, SkipHidden: true, Sort: SortByPath},
+			[]string{"a.csv", "skip/e.csv", "sub/c.csv", "sub/deep/d.csv"}},
+	}
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := tt.root
+			if root == "" {
+				root = dir
+			}
+			files, err := ListFiles(root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range files {
+				got = append(got, filepath.ToSlash(f.RelPath))
+				if f.Path != filepath.Join(root, f.RelPath) {
+					t.Errorf("got path %s for %s", f.Path, f.RelPath)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 // It returns an error if it encounters a problem while reading the specified directory.
 // For each file in the directory that is not another directory, it will log a warning message that includes the file's name.
 // If the function execution is successful, it will return nil, indicating no error occurred.
+// Use AuditDir to report only the files which are not expected.
 func LogFilesInDir(dirPath string, logPtr *logrus.Entry) error {
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
@@ -47,6 +48,8 @@ func LogFilesInDir(dirPath string, logPtr *logrus.Entry) error {
 // Returns:
 //   - nil if the directory was successfully traversed and all files within were logged without issue.
 //   - error if there was a problem traversing the directory, or if there were any other errors.
+//
+// Use AuditDir with Recursive to report only the files which are not expected.
 func LogFilesInDirRecursive(dirPath string, logPtr *logrus.Entry) error {
 	return filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -0,0 +1,260 @@
+package filehelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrMarkerTimeout is returned by WaitForMarker if the marker does not appear in time
+var ErrMarkerTimeout = errors.New("timeout waiting for marker file")
+
+// DefaultMarkerSuffix is the suffix of marker files if none is given
+const DefaultMarkerSuffix = ".done"
+
+// markerPollInterval is the pause between the checks of WaitForMarker
+const markerPollInterval = 500 * time.Millisecond
+
+// MarkerInfo is the JSON payload of a marker file. Marker files written by partners may be empty or
+// hold plain text, which gives a zero MarkerInfo.
+type MarkerInfo struct {
+	File      string        `json:"file,omitempty"`      // name of the data file
+	Timestamp time.Time     `json:"timestamp"`           // when the data file was completed
+	Size      int64         `json:"size"`                // size of the data file in bytes
+	Algorithm HashAlgorithm `json:"algorithm,omitempty"` // algorithm of Checksum
+	Checksum  string        `json:"checksum,omitempty"`  // hex checksum of the data file
+	Rows      int64         `json:"rows,omitempty"`      // number of data rows, if known
+}
+
+// MarkerOptions controls WriteMarker
+type MarkerOptions struct {
+	Suffix   string        // suffix of the marker, e.g. ".ok" or ".ready", DefaultMarkerSuffix if empty
+	Checksum HashAlgorithm // record a checksum of the data file if set
+	Rows     int64         // number of data rows to record, 0 leaves it out
+}
+
+// MarkerPair is a data file and its marker found by PairMarkers
+type MarkerPair struct {
+	Data   string
+	Marker string
+}
+
+// MarkerPairs is the result of PairMarkers
+type MarkerPairs struct {
+	Pairs    []MarkerPair // data files with their markers, ready to be processed
+	Waiting  []string     // data files without a marker yet
+	Orphaned []string     // markers without a data file
+}
+
+// MarkerPath returns the path of the marker of a data file, the data path with the suffix appended
+// (data.csv -> data.csv.done). The suffix defaults to DefaultMarkerSuffix.
+func MarkerPath(dataPath, suffix string) string {
+	if suffix == "" {
+		suffix = DefaultMarkerSuffix
+	}
+	return dataPath + suffix
+}
+
+// WriteMarker writes the marker of the completed data file at dataPath, with size, time and optionally
+// checksum and row count of the data as JSON payload. The marker is written atomically, so a reader
+// never sees a partial payload. It returns the payload.
+func WriteMarker(dataPath string, opts MarkerOptions) (MarkerInfo, error) {
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return MarkerInfo{}, err
+	}
+
+	marker := MarkerInfo{
+		File:      filepath.Base(dataPath),
+		Timestamp: time.Now(),
+		Size:      info.Size(),
+		Rows:      opts.Rows,
+	}
+	if opts.Checksum != "" {
+		marker.Algorithm = opts.Checksum
+		if marker.Checksum, err = FileChecksum(dataPath, opts.Checksum); err != nil {
+			return marker, err
+		}
+	}
+
+	content, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return marker, err
+	}
+	return marker, WriteFileAtomic(MarkerPath(dataPath, opts.Suffix), content, 0644)
+}
+
+// ReadMarker reads the payload of a marker file. Partners often write markers without payload, which
+// give a zero MarkerInfo: an empty file or content which is not a JSON object, e.g. "OK" or a timestamp.
+// Content starting like a JSON object has to be valid, otherwise it is an error.
+func ReadMarker(markerPath string) (MarkerInfo, error) {
+	content, err := os.ReadFile(markerPath)
+	if err != nil {
+		return MarkerInfo{}, err
+	}
+	marker, _, err := parseMarker(markerPath, content)
+	return marker, err
+}
+
+// parseMarker decodes the content of a marker file and reports whether it has a JSON payload
+func parseMarker(markerPath string, content []byte) (MarkerInfo, bool, error) {
+	var marker MarkerInfo
+	trimmed := bytes.TrimSpace(content)
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		return marker, false, nil
+	}
+	if err := json.Unmarshal(trimmed, &marker); err != nil {
+		return marker, true, fmt.Errorf("invalid marker %s: %w", markerPath, err)
+	}
+	return marker, true, nil
+}
+
+// VerifyMarker checks the data file against the size and checksum recorded in its marker.
+// Markers without payload only prove the data file is complete and always pass. It returns an error
+// wrapping ErrChecksumMismatch if the data differs.
+func VerifyMarker(dataPath, markerPath string) error {
+	marker, err := ReadMarker(markerPath)
+	if err != nil {
+		return err
+	}
+	if marker.File == "" && marker.Checksum == "" && marker.Size == 0 {
+		return nil
+	}
+
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return err
+	}
+	if info.Size() != marker.Size {
+		return fmt.Errorf("%s: size %d, marker records %d: %w", dataPath, info.Size(), marker.Size, ErrChecksumMismatch)
+	}
+	if marker.Checksum == "" {
+		return nil
+	}
+	sum, err := FileChecksum(dataPath, marker.Algorithm)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, marker.Checksum) {
+		return fmt.Errorf("%s: %w", dataPath, ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// WaitForMarker waits until the marker file exists and has a valid payload, checking every half
+// second. It returns an error wrapping ErrMarkerTimeout if the marker does not appear within timeout.
+//
+// A partner may create the marker empty and write its payload afterwards. So a marker without JSON
+// payload is only accepted once its content did not change between two checks, which delays it by
+// half a second; a complete JSON payload is accepted at once.
+func WaitForMarker(markerPath string, timeout time.Duration) (MarkerInfo, error) {
+	deadline := time.Now().Add(timeout)
+	var previous []byte
+	seen := false
+	for {
+		var marker MarkerInfo
+		content, err := os.ReadFile(markerPath)
+		if err == nil {
+			var payload bool
+			marker, payload, err = parseMarker(markerPath, content)
+			if err == nil && (payload || (seen && bytes.Equal(previous, content))) {
+				return marker, nil
+			}
+			previous, seen = content, true
+		}
+		// A partner may still be writing the payload, so invalid content is retried as well
+		if !time.Now().Before(deadline) {
+			if err == nil {
+				// The marker exists, but did not settle before the deadline
+				return marker, nil
+			}
+			if os.IsNotExist(err) {
+				return marker, fmt.Errorf("%s: %w", markerPath, ErrMarkerTimeout)
+			}
+			return marker, fmt.Errorf("%s: %w: %w", markerPath, ErrMarkerTimeout, err)
+		}
+
+		pause := markerPollInterval
+		if remaining := time.Until(deadline); remaining < pause {
+			pause = remaining
+		}
+		time.Sleep(pause)
+	}
+}
+
+// PairMarkers pairs the files in dir with their markers with the suffix (DefaultMarkerSuffix if empty).
+// A marker belongs to the data file named like the marker without the suffix (data.csv.done) or, if
+// there is none, to the data file with the same base name and any extension (data.done for data.csv).
+// Temporary files of an AtomicWriter and checksum sidecars of data files (data.csv.sha256) are no
+// data files, so files still being written do not show up as waiting.
+func PairMarkers(dir, suffix string) (MarkerPairs, error) {
+	var result MarkerPairs
+	if suffix == "" {
+		suffix = DefaultMarkerSuffix
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return result, err
+	}
+
+	var markers []string
+	data := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || isAtomicTemp(entry.Name()) {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), suffix) {
+			markers = append(markers, entry.Name())
+		} else {
+			data[entry.Name()] = true
+		}
+	}
+	for name := range data {
+		for _, algo := range []HashAlgorithm{MD5, SHA1, SHA256} {
+			if strings.HasSuffix(name, algo.Extension()) && data[strings.TrimSuffix(name, algo.Extension())] {
+				delete(data, name)
+			}
+		}
+	}
+
+	// Data files by their name without extension, for markers replacing the extension
+	byBase := make(map[string][]string)
+	for name := range data {
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		byBase[base] = append(byBase[base], name)
+	}
+
+	paired := make(map[string]bool)
+	for _, marker := range markers {
+		name := strings.TrimSuffix(marker, suffix)
+		switch {
+		case data[name]:
+			paired[name] = true
+			result.Pairs = append(result.Pairs, MarkerPair{Data: filepath.Join(dir, name), Marker: filepath.Join(dir, marker)})
+		case len(byBase[name]) > 0:
+			for _, dataName := range byBase[name] {
+				paired[dataName] = true
+				result.Pairs = append(result.Pairs, MarkerPair{Data: filepath.Join(dir, dataName), Marker: filepath.Join(dir, marker)})
+			}
+		default:
+			result.Orphaned = append(result.Orphaned, filepath.Join(dir, marker))
+		}
+	}
+
+	for name := range data {
+		if !paired[name] {
+			result.Waiting = append(result.Waiting, filepath.Join(dir, name))
+		}
+	}
+	sort.Slice(result.Pairs, func(i, j int) bool { return result.Pairs[i].Data < result.Pairs[j].Data })
+	sort.Strings(result.Waiting)
+
+	return result, nil
+}
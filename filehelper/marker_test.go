@@ -0,0 +1,166 @@
+package filehelper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadMarker(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		content string
+		want    MarkerInfo
+		wantErr bool
+	}{
+		// the table itself
+		{"POS empty", "", MarkerInfo{}, false},
+		{"POS plain text", "OK\n", MarkerInfo{}, false},
+		{"POS timestamp", "2024-06-30T12:00:00Z", MarkerInfo{}, false},
+		{"POS payload", `{"file": "data.csv", "size": 42, "rows": 3}`, MarkerInfo{File: "data.csv", Size: 42, Rows: 3}, false},
+		{"NEG truncated payload", `{"file": "data.csv", "si`, MarkerInfo{}, true},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.csv.done")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadMarker(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadMarker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadMarker() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyMarker(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name     string
+		checksum HashAlgorithm
+		marker   string // replaces the written marker if not empty
+		data     string // content of the data file at verification
+		want     error
+	}{
+		// the table itself
+		{"POS unchanged", "", "", "a;b;c\n", nil},
+		{"POS unchanged with checksum", SHA256, "", "a;b;c\n", nil},
+		{"POS marker without payload", "", "OK", "changed", nil},
+		{"NEG size changed", "", "", "a;b;c;d\n", ErrChecksumMismatch},
+		{"NEG content changed", SHA256, "", "x;y;z\n", ErrChecksumMismatch},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataPath := filepath.Join(t.TempDir(), "data.csv")
+			if err := os.WriteFile(dataPath, []byte("a;b;c\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			written, err := WriteMarker(dataPath, MarkerOptions{Checksum: tt.checksum, Rows: 1})
+			if err != nil {
+				t.Fatalf("WriteMarker() error = %v", err)
+			}
+			markerPath := MarkerPath(dataPath, "")
+			read, err := ReadMarker(markerPath)
+			if err != nil {
+				t.Fatalf("ReadMarker() error = %v", err)
+			}
+			if read.File != "data.csv" || read.Size != 6 || read.Rows != 1 || read.Checksum != written.Checksum || !read.Timestamp.Equal(written.Timestamp) {
+				t.Errorf("ReadMarker() = %+v, want %+v", read, written)
+			}
+			if (tt.checksum != "") != (read.Checksum != "") {
+				t.Errorf("ReadMarker() checksum = %q with algorithm %q", read.Checksum, tt.checksum)
+			}
+
+			if tt.marker != "" {
+				if err := os.WriteFile(markerPath, []byte(tt.marker), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.WriteFile(dataPath, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyMarker(dataPath, markerPath); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyMarker() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForMarker(t *testing.T) {
+	dir := t.TempDir()
+
+	// A marker created empty and filled afterwards is read with its payload
+	markerPath := filepath.Join(dir, "data.csv.done")
+	if err := os.WriteFile(markerPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = os.WriteFile(markerPath, []byte(`{"file": "data.csv", "size": 6}`), 0644)
+	}()
+	marker, err := WaitForMarker(markerPath, 2*time.Second)
+	if err != nil {
+		t.Fatalf("WaitForMarker() error = %v", err)
+	}
+	if marker.File != "data.csv" || marker.Size != 6 {
+		t.Errorf("WaitForMarker() = %+v, want the payload written after creation", marker)
+	}
+
+	// A marker without payload is accepted once it is stable
+	plainPath := filepath.Join(dir, "other.csv.done")
+	if err := os.WriteFile(plainPath, []byte("OK"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if marker, err := WaitForMarker(plainPath, 2*time.Second); err != nil || marker != (MarkerInfo{}) {
+		t.Errorf("WaitForMarker() = %+v, %v, want a marker without payload", marker, err)
+	}
+
+	if _, err := WaitForMarker(filepath.Join(dir, "missing.csv.done"), 100*time.Millisecond); !errors.Is(err, ErrMarkerTimeout) {
+		t.Errorf("WaitForMarker() error = %v, want %v", err, ErrMarkerTimeout)
+	}
+}
+
+func TestPairMarkers(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir, map[string]string{
+		"a.csv":      "a",
+		"a.csv.done": "",
+		"b.csv":      "b",
+		"b.done":     "",
+		"c.csv":      "c",
+		"d.csv.done": "",
+		"sub/e.csv":  "e",
+		// in-flight upload of f.csv and the checksum of a.csv
+		".f.csv.tmp-12345": "f",
+		"a.csv.sha256":     "",
+	})
+
+	got, err := PairMarkers(dir, "")
+	if err != nil {
+		t.Fatalf("PairMarkers() error = %v", err)
+	}
+	want := MarkerPairs{
+		Pairs: []MarkerPair{
+			{Data: filepath.Join(dir, "a.csv"), Marker: filepath.Join(dir, "a.csv.done")},
+			{Data: filepath.Join(dir, "b.csv"), Marker: filepath.Join(dir, "b.done")},
+		},
+		Waiting:  []string{filepath.Join(dir, "c.csv")},
+		Orphaned: []string{filepath.Join(dir, "d.csv.done")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PairMarkers() = %+v, want %+v", got, want)
+	}
+}
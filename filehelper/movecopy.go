@@ -1,102 +1,289 @@
 package filehelper
 
 import (
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
 	"io"
 	"os"
 	"path/filepath"
+	"syscall"
 )
 
+// CopyOptions controls how CopyFileWithOptions and MoveFileWithOptions treat the destination.
+// Permissions and modification time are always preserved.
+type CopyOptions struct {
+	Conflict       ConflictPolicy // what to do if the destination exists, overwrite by default
+	PreserveOwner  bool           // copy uid and gid, usually requires root
+	PreserveXattrs bool           // copy extended attributes, where the file system supports them
+}
+
 // MoveFile moves a file from sourcePath to destDir with the filename provided.
 // The function takes three parameters:
 // sourcePath is the path to the file that needs to be moved
 // destDir is the directory to which the file needs to be moved
 // filename is the new name of the file once it is moved to the destination directory
 //
-// MoveFile attempts to open the source file, create the destination directory if it doesn't already exist,
-// create a new file in the destination directory, copy the contents from the source file to the dest file,
-// and finally delete the source file.
+// MoveFile creates the destination directory if it doesn't already exist and renames the file.
+// If source and destination are on different file systems, it copies the contents to the destination
+// atomically (see AtomicWriter), keeping permissions and modification time, and finally deletes the
+// source file. An existing destination file is replaced.
 //
 // It returns an error if any of the steps fail, otherwise it returns nil indicating successful move.
 func MoveFile(sourcePath, destDir, filename string) error {
-	sourceFile, err := os.Open(sourcePath)
+	_, _, err := MoveFileWithOptions(sourcePath, destDir, filename, CopyOptions{})
+	return err
+}
+
+// MoveFileWithOptions works like MoveFile, but applies the conflict policy of opts if the destination
+// exists and can preserve ownership and extended attributes when the file has to be copied.
+// It returns the path the file was moved to (which differs from destDir/filename with ConflictRename)
+// and what happened: ActionCreated, ActionOverwritten, ActionRenamed or ActionSkipped.
+// A skipped file stays at sourcePath. Unless the policy is ConflictOverwrite, an existing file is
+// never replaced, even if it is created at the destination while the file is moved.
+func MoveFileWithOptions(sourcePath, destDir, filename string, opts CopyOptions) (string, EntryAction, error) {
+	info, err := os.Stat(sourcePath)
 	if err != nil {
-		return err
+		return "", ActionSkipped, err
+	}
+	if info.IsDir() {
+		return "", ActionSkipped, fmt.Errorf("move %s: is a directory", sourcePath)
 	}
-	defer func(sourcefile *os.File) {
-		_ = sourceFile.Close()
-	}(sourceFile)
 
 	// Create the destination directory if it doesn't exist
-	err = os.MkdirAll(destDir, 0755)
-	if err != nil {
-		return err
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", ActionSkipped, err
 	}
 
-	destPath := filepath.Join(destDir, filename)
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		return err
+	// Renaming keeps all metadata and is atomic on the same file system
+	destPath, action, err := placeFile(sourcePath, filepath.Join(destDir, filename), opts.Conflict)
+	if err == nil {
+		if action == ActionSkipped {
+			return destPath, action, nil
+		}
+		return destPath, action, syncDir(destDir)
 	}
-	defer func(destFile *os.File) {
-		_ = destFile.Close()
-	}(destFile)
-
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return err
+	if !errors.Is(err, unix.EXDEV) {
+		return "", ActionSkipped, err
 	}
 
-	err = sourceFile.Close()
-	if err != nil {
-		return err
+	destPath, action, err = copyFileContent(sourcePath, filepath.Join(destDir, filename), info, opts)
+	if err != nil || action == ActionSkipped {
+		return destPath, action, err
 	}
 
-	err = os.Remove(sourcePath) // Remove the source file after successful move
+	err = os.Remove(sourcePath) // Remove the source file after successful copy
 	if err != nil {
-		return err
+		return destPath, action, err
 	}
 
-	return nil
+	return destPath, action, nil
 }
 
 // CopyFile copies a file from sourcePath to a destination directory destDir with a new filename.
 // It opens the source file and creates a destination directory if not present.
-// Then it creates a new file in the destination directory and copies the source file contents to the new file.
+// Then it writes the source file contents to a temporary file in the destination directory and
+// renames it to the new filename once the copy is complete and synced (see AtomicWriter).
+// Permissions and modification time of the source are kept, an existing destination file is replaced.
 // File handlers are also properly closed after their operations are over.
 // If any error occurs during these operations, it will be returned by the function.
 func CopyFile(sourcePath, destDir, filename string) error {
-	sourceFile, err := os.Open(sourcePath)
+	_, _, err := CopyFileWithOptions(sourcePath, destDir, filename, CopyOptions{})
+	return err
+}
+
+// CopyFileWithOptions works like CopyFile, but applies the conflict policy of opts if the destination
+// exists and can preserve ownership and extended attributes. It returns the path of the copy and
+// what happened, like MoveFileWithOptions. The metadata is applied before the copy becomes visible.
+func CopyFileWithOptions(sourcePath, destDir, filename string, opts CopyOptions) (string, EntryAction, error) {
+	info, err := os.Stat(sourcePath)
 	if err != nil {
-		return err
+		return "", ActionSkipped, err
+	}
+	if info.IsDir() {
+		return "", ActionSkipped, fmt.Errorf("copy %s: is a directory", sourcePath)
 	}
-	defer func(sourcefile *os.File) {
-		_ = sourceFile.Close()
-	}(sourceFile)
 
 	// Create the destination directory if it doesn't exist
-	err = os.MkdirAll(destDir, 0755)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", ActionSkipped, err
+	}
+
+	return copyFileContent(sourcePath, filepath.Join(destDir, filename), info, opts)
+}
+
+// resolveDestination applies the conflict policy to destPath and returns the path to write to
+func resolveDestination(destPath string, policy ConflictPolicy) (string, EntryAction, error) {
+	info, err := os.Lstat(destPath)
+	if os.IsNotExist(err) {
+		return destPath, ActionCreated, nil
+	}
 	if err != nil {
+		return "", ActionSkipped, err
+	}
+	if info.IsDir() {
+		return "", ActionSkipped, fmt.Errorf("%s: destination is a directory", destPath)
+	}
+
+	switch policy {
+	case ConflictSkip:
+		return destPath, ActionSkipped, nil
+	case ConflictFail:
+		return "", ActionSkipped, &os.PathError{Op: "copy", Path: destPath, Err: os.ErrExist}
+	case ConflictRename:
+		renamed, err := uniquePath(destPath)
+		return renamed, ActionRenamed, err
+	default:
+		return destPath, ActionOverwritten, nil
+	}
+}
+
+// placeFile moves the file at path to destPath according to the conflict policy and returns the
+// final path and what happened. Unless the policy is ConflictOverwrite, an existing file is never
+// replaced: if one appears at the destination after it was checked, the policy is applied again.
+func placeFile(path, destPath string, policy ConflictPolicy) (string, EntryAction, error) {
+	for {
+		target, action, err := resolveDestination(destPath, policy)
+		if err != nil || action == ActionSkipped {
+			return target, action, err
+		}
+		if policy == ConflictOverwrite {
+			return target, action, os.Rename(path, target)
+		}
+		if err := renameNoReplace(path, target); !errors.Is(err, os.ErrExist) {
+			return target, action, err
+		}
+	}
+}
+
+// linkRename moves oldPath to newPath without replacing an existing file, by creating a hard link
+// and removing the old name. File systems without hard links fall back to a checked rename.
+func linkRename(oldPath, newPath string) error {
+	err := os.Link(oldPath, newPath)
+	if err == nil {
+		// If the old name was removed meanwhile, the file was moved nevertheless
+		if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if errors.Is(err, os.ErrExist) || errors.Is(err, unix.EXDEV) {
 		return err
 	}
+	if _, statErr := os.Lstat(newPath); statErr == nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrExist}
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// copyFileContent copies the source file to destPath through an AtomicWriter, applies the metadata
+// of the source while the copy is still a temporary file and puts it in place according to the
+// conflict policy of opts. It returns the path of the copy and what happened.
+func copyFileContent(sourcePath, destPath string, info os.FileInfo, opts CopyOptions) (string, EntryAction, error) {
+	target, action, err := resolveDestination(destPath, opts.Conflict)
+	if err != nil || action == ActionSkipped {
+		return target, action, err
+	}
 
-	destPath := filepath.Join(destDir, filename)
-	destFile, err := os.Create(destPath)
+	sourceFile, err := os.Open(sourcePath)
 	if err != nil {
-		return err
+		return "", ActionSkipped, err
+	}
+	defer func(sourceFile *os.File) {
+		_ = sourceFile.Close()
+	}(sourceFile)
+
+	destFile, err := NewAtomicWriter(target, info.Mode().Perm())
+	if err != nil {
+		return "", ActionSkipped, err
 	}
-	defer func(destfile *os.File) {
-		_ = destFile.Close()
-	}(destFile)
+	defer destFile.Abort()
+	// The copy gets the mode of the source, which the umask was applied to when it was created
+	destFile.exactPerm = true
 
 	_, err = io.Copy(destFile, sourceFile)
 	if err != nil {
+		return "", ActionSkipped, err
+	}
+
+	err = destFile.closeWith(func(tmp string) error {
+		return copyMetadata(sourcePath, tmp, info, opts)
+	}, func(tmp string) error {
+		target, action, err = placeFile(tmp, destPath, opts.Conflict)
+		return err
+	})
+	if err != nil {
+		return "", ActionSkipped, err
+	}
+	return target, action, nil
+}
+
+// copyMetadata applies modification time and, if requested, ownership and extended
+// attributes of the source to dest. Permissions are set by the AtomicWriter.
+func copyMetadata(sourcePath, destPath string, info os.FileInfo, opts CopyOptions) error {
+	if opts.PreserveOwner {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if err := os.Lchown(destPath, int(stat.Uid), int(stat.Gid)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.PreserveXattrs {
+		if err := copyXattrs(sourcePath, destPath); err != nil {
+			return err
+		}
+	}
+
+	return os.Chtimes(destPath, info.ModTime(), info.ModTime())
+}
+
+// copyXattrs copies all extended attributes from source to dest. File systems without
+// extended attribute support are ignored.
+func copyXattrs(sourcePath, destPath string) error {
+	size, err := unix.Listxattr(sourcePath, nil)
+	if err != nil || size == 0 {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil
+		}
 		return err
 	}
 
-	err = sourceFile.Close()
+	names := make([]byte, size)
+	size, err = unix.Listxattr(sourcePath, names)
 	if err != nil {
 		return err
 	}
 
+	for _, name := range splitXattrNames(names[:size]) {
+		valueSize, err := unix.Getxattr(sourcePath, name, nil)
+		if err != nil {
+			return err
+		}
+		value := make([]byte, valueSize)
+		valueSize, err = unix.Getxattr(sourcePath, name, value)
+		if err != nil {
+			return err
+		}
+		if err := unix.Setxattr(destPath, name, value[:valueSize], 0); err != nil && !errors.Is(err, unix.ENOTSUP) {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// splitXattrNames splits the NUL separated list returned by Listxattr
+func splitXattrNames(list []byte) []string {
+	var names []string
+	start := 0
+	for i, b := range list {
+		if b == 0 {
+			if i > start {
+				names = append(names, string(list[start:i]))
+			}
+			start = i + 1
+		}
+	}
+	return names
+}
@@ -0,0 +1,20 @@
+package filehelper
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// renameNoReplace renames oldPath to newPath, failing with an error wrapping os.ErrExist if newPath
+// exists. File systems without support for RENAME_NOREPLACE fall back to linkRename.
+func renameNoReplace(oldPath, newPath string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, oldPath, unix.AT_FDCWD, newPath, unix.RENAME_NOREPLACE)
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.ENOTSUP) {
+		return linkRename(oldPath, newPath)
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package filehelper
+
+// renameNoReplace renames oldPath to newPath, failing with an error wrapping os.ErrExist if newPath
+// exists, see linkRename
+func renameNoReplace(oldPath, newPath string) error {
+	return linkRename(oldPath, newPath)
+}
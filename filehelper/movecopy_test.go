@@ -0,0 +1,144 @@
+package filehelper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// otherDeviceDir returns a temporary directory on another file system than t.TempDir(), or skips the test
+func otherDeviceDir(t *testing.T) string {
+	t.Helper()
+	var local, shm syscall.Stat_t
+	if syscall.Stat(t.TempDir(), &local) != nil || syscall.Stat("/dev/shm", &shm) != nil || local.Dev == shm.Dev {
+		t.Skip("no second file system available")
+	}
+	dir, err := os.MkdirTemp("/dev/shm", "filehelper-test-")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestMoveCopyConflict(t *testing.T) {
+	modTime := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name     string
+		existing bool // report.csv already exists in the destination with content "old"
+		policy   ConflictPolicy
+		want     EntryAction
+		target   string // file name holding "new" afterwards, empty if nothing was written
+		files    int    // number of files in the destination afterwards
+		wantErr  error
+	}{
+		// the table itself
+		{"POS no conflict", false, ConflictFail, ActionCreated, "report.csv", 1, nil},
+		{"POS overwrite", true, ConflictOverwrite, ActionOverwritten, "report.csv", 1, nil},
+		{"POS skip", true, ConflictSkip, ActionSkipped, "", 1, nil},
+		{"POS rename", true, ConflictRename, ActionRenamed, "report_1.csv", 2, nil},
+		{"NEG fail", true, ConflictFail, ActionSkipped, "", 1, os.ErrExist},
+	}
+
+	// The execution loop
+	for _, mode := range []string{"copy", "move", "move cross-device"} {
+		for _, tt := range tests {
+			t.Run(mode+" "+tt.name, func(t *testing.T) {
+				source := filepath.Join(t.TempDir(), "in.csv")
+				if err := os.WriteFile(source, []byte("new"), 0640); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(source, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+				dest := t.TempDir()
+				if mode == "move cross-device" {
+					dest = otherDeviceDir(t)
+				}
+				if tt.existing {
+					if err := os.WriteFile(filepath.Join(dest, "report.csv"), []byte("old"), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				var got string
+				var action EntryAction
+				var err error
+				if mode == "copy" {
+					got, action, err = CopyFileWithOptions(source, dest, "report.csv", CopyOptions{Conflict: tt.policy})
+				} else {
+					got, action, err = MoveFileWithOptions(source, dest, "report.csv", CopyOptions{Conflict: tt.policy})
+				}
+				if !errors.Is(err, tt.wantErr) || action != tt.want {
+					t.Fatalf("got %v : %v, want %v : %v", action, err, tt.want, tt.wantErr)
+				}
+
+				// An existing file is only replaced with ConflictOverwrite
+				if tt.existing && tt.policy != ConflictOverwrite {
+					if content, err := os.ReadFile(filepath.Join(dest, "report.csv")); err != nil || string(content) != "old" {
+						t.Errorf("existing file changed to %q : %v", content, err)
+					}
+				}
+				// The source is only gone if it was moved
+				_, statErr := os.Stat(source)
+				if moved := mode != "copy" && tt.target != ""; moved != os.IsNotExist(statErr) {
+					t.Errorf("got source %v, moved %t", statErr, moved)
+				}
+				// No temporary file may be left behind
+				entries, err := os.ReadDir(dest)
+				if err != nil || len(entries) != tt.files {
+					t.Errorf("got %d files in the destination : %v, want %d", len(entries), err, tt.files)
+				}
+				if tt.target == "" {
+					return
+				}
+
+				if want := filepath.Join(dest, tt.target); got != want {
+					t.Errorf("got %s, want %s", got, want)
+				}
+				content, err := os.ReadFile(got)
+				if err != nil || string(content) != "new" {
+					t.Errorf("got %q : %v, want %q", content, err, "new")
+				}
+				info, err := os.Stat(got)
+				if err != nil || !info.ModTime().Equal(modTime) || info.Mode().Perm() != 0640 {
+					t.Errorf("got %v %v : %v, want %s 0640", info.ModTime(), info.Mode().Perm(), err, modTime)
+				}
+			})
+		}
+	}
+}
+
+func TestRenameNoReplace(t *testing.T) {
+	for name, rename := range map[string]func(oldPath, newPath string) error{"renameNoReplace": renameNoReplace, "linkRename": linkRename} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+			writeTestTree(t, dir, map[string]string{"a": "a", "b": "b"})
+
+			if err := rename(a, b); !errors.Is(err, os.ErrExist) {
+				t.Errorf("got %v, want %v", err, os.ErrExist)
+			}
+			if content, err := os.ReadFile(b); err != nil || string(content) != "b" {
+				t.Errorf("got %q : %v, want %q", content, err, "b")
+			}
+
+			if err := rename(a, c); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(a); !os.IsNotExist(err) {
+				t.Errorf("got %v, want the old name removed", err)
+			}
+			if content, err := os.ReadFile(c); err != nil || string(content) != "a" {
+				t.Errorf("got %q : %v, want %q", content, err, "a")
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package filehelper
+
+import (
+	"errors"
+	"fmt"
+	"github.com/smithyat/go-helpers/stringhelper"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DateSource selects where OrganizeFiles takes the date of a file from
+type DateSource int
+
+const (
+	DateFromNameOrModTime DateSource = iota // the date in the file name, the modification time if there is none
+	DateFromName                            // only the date in the file name, files without one are undated
+	DateFromModTime                         // only the modification time
+)
+
+// OrganizeOptions controls OrganizeFiles
+type OrganizeOptions struct {
+	// Template is the directory below the destination root a file is put into, built from its date.
+	// Placeholders are {yyyy} (year), {yy} (two-digit year), {mm} (month), {dd} (day),
+	// {ww} (ISO week), {gggg} (ISO week-numbering year) and {q} (quarter), e.g. "{yyyy}/{mm}/{dd}"
+	// or Hive-style "year={yyyy}/month={mm}". Pair {ww} with {gggg}, not {yyyy}: the days around
+	// New Year can belong to the week of the neighbouring year, e.g. 2024-12-30 is in "2025/01".
+	Template string
+
+	DateSource DateSource     // where the date of a file comes from
+	Location   *time.Location // time zone of the modification times, time.Local if nil
+	Fallback   string         // directory below the destination root for undated files, empty leaves them in place
+	Copy       bool           // copy the files instead of moving them
+	Conflict   ConflictPolicy // what to do if the target file exists
+	DryRun     bool           // only report the targets, do not touch any file
+}
+
+// OrganizedFile is the outcome of OrganizeFiles for one file
+type OrganizedFile struct {
+	Source string
+	Target string      // path the file was (or would be) put at, empty if it was left in place
+	Date   time.Time   // date the target directory was built from, zero for undated files
+	Action EntryAction // ActionCreated, ActionOverwritten, ActionRenamed or ActionSkipped
+	Err    error
+}
+
+// OrganizeReport is the result of OrganizeFiles
+type OrganizeReport struct {
+	Files   []OrganizedFile
+	Undated int // files without a date, put into the fallback directory or left in place
+}
+
+// Err returns the errors of the single files joined into one, or nil if there were none
+func (r OrganizeReport) Err() error {
+	var errs []error
+	for _, file := range r.Files {
+		if file.Err != nil {
+			errs = append(errs, file.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OrganizeFiles moves (or copies) the files, e.g. from GetFiles or ListFiles, into a date-partitioned
+// directory tree below destRoot. The date of a file is the one embedded in its name (see
+// stringhelper.ExtractDate) or its modification time, depending on opts.DateSource.
+//
+// Example: with the template "{yyyy}/{mm}/{dd}", the file in/export_20240630.csv is moved to
+// destRoot/2024/06/30/export_20240630.csv.
+//
+// All files are tried, the error of each file is recorded in the report. The returned error is only
+// set if the template or the fallback directory is invalid.
+func OrganizeFiles(files []FileInfo, destRoot string, opts OrganizeOptions) (OrganizeReport, error) {
+	var report OrganizeReport
+	if err := checkDateTemplate(opts.Template); err != nil {
+		return report, err
+	}
+	if opts.Fallback != "" && !filepath.IsLocal(filepath.FromSlash(opts.Fallback)) {
+		return report, fmt.Errorf("fallback directory %q leaves the destination directory", opts.Fallback)
+	}
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	for _, file := range files {
+		result := OrganizedFile{Source: file.Path, Action: ActionSkipped}
+
+		date, dated := fileDate(file, opts.DateSource, loc)
+		var destDir string
+		switch {
+		case dated:
+			result.Date = date
+			destDir = filepath.Join(destRoot, filepath.FromSlash(ExpandDateTemplate(opts.Template, date)))
+		case opts.Fallback != "":
+			report.Undated++
+			destDir = filepath.Join(destRoot, filepath.FromSlash(opts.Fallback))
+		default:
+			report.Undated++
+			report.Files = append(report.Files, result)
+			continue
+		}
+
+		if samePath(file.Path, filepath.Join(destDir, file.Name)) {
+			// Already organized, e.g. if the destination root is the scanned directory
+			report.Files = append(report.Files, result)
+			continue
+		}
+
+		copyOpts := CopyOptions{Conflict: opts.Conflict}
+		switch {
+		case opts.DryRun:
+			result.Target, result.Action, result.Err = resolveDestination(filepath.Join(destDir, file.Name), opts.Conflict)
+		case opts.Copy:
+			result.Target, result.Action, result.Err = CopyFileWithOptions(file.Path, destDir, file.Name, copyOpts)
+		default:
+			result.Target, result.Action, result.Err = MoveFileWithOptions(file.Path, destDir, file.Name, copyOpts)
+		}
+		if result.Action == ActionSkipped {
+			result.Target = ""
+		}
+		report.Files = append(report.Files, result)
+	}
+
+	return report, nil
+}
+
+// ExpandDateTemplate replaces the placeholders of an OrganizeOptions template with the parts of date
+func ExpandDateTemplate(template string, date time.Time) string {
+	isoYear, week := date.ISOWeek()
+	return strings.NewReplacer(
+		"{gggg}", fmt.Sprintf("%04d", isoYear),
+		"{yyyy}", date.Format("2006"),
+		"{yy}", date.Format("06"),
+		"{mm}", date.Format("01"),
+		"{dd}", date.Format("02"),
+		"{ww}", fmt.Sprintf("%02d", week),
+		"{q}", fmt.Sprintf("%d", (int(date.Month())-1)/3+1),
+	).Replace(template)
+}
+
+// checkDateTemplate rejects templates which are empty, have unknown placeholders or leave the destination root
+func checkDateTemplate(template string) error {
+	if template == "" {
+		return errors.New("empty date template")
+	}
+	rest := ExpandDateTemplate(template, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("unknown placeholder in date template %q", template)
+	}
+	if !filepath.IsLocal(filepath.FromSlash(rest)) {
+		return fmt.Errorf("date template %q leaves the destination directory", template)
+	}
+	return nil
+}
+
+// samePath reports whether both paths denote the same location, after making them absolute
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// fileDate returns the date of a file according to source, false if it has none
+func fileDate(file FileInfo, source DateSource, loc *time.Location) (time.Time, bool) {
+	if source != DateFromModTime {
+		if date, err := stringhelper.ExtractDate(file.Name); err == nil {
+			if year, month, day, err := stringhelper.SplitDate(date); err == nil {
+				if t, err := time.ParseInLocation("2006-01-02", year+"-"+month+"-"+day, loc); err == nil {
+					return t, true
+				}
+			}
+		}
+		if source == DateFromName {
+			return time.Time{}, false
+		}
+	}
+	if file.ModTime.IsZero() {
+		return time.Time{}, false
+	}
+	return file.ModTime.In(loc), true
+}
@@ -0,0 +1,142 @@
+package filehelper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpandDateTemplate(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name     string
+		template string
+		date     time.Time
+		want     string
+	}{
+		// the table itself
+		{"POS year month day", "{yyyy}/{mm}/{dd}", time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC), "2024/06/30"},
+		{"POS hive layout", "year={yyyy}/month={mm}", time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC), "year=2024/month=06"},
+		{"POS two-digit year and quarter", "{yy}-Q{q}", time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), "24-Q4"},
+		{"POS iso week", "{gggg}/{ww}", time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC), "2024/26"},
+		{"POS iso week of next year", "{gggg}/{ww}", time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC), "2025/01"},
+		{"POS iso week of previous year", "{gggg}/{ww}", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), "2026/53"},
+		{"NEG calendar year with iso week", "{yyyy}/{ww}", time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC), "2024/01"},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandDateTemplate(tt.template, tt.date); got != tt.want {
+				t.Errorf("ExpandDateTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrganizeFiles(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name        string
+		files       map[string]string
+		inPlace     bool // organize into the scanned directory itself
+		opts        OrganizeOptions
+		want        map[string]string
+		wantUndated int
+		wantErr     bool
+	}{
+		// the table itself
+		{
+			name:  "POS move by date in name",
+			files: map[string]string{"export_20240630.csv": "a", "export_20240701.csv": "b"},
+			opts:  OrganizeOptions{Template: "{yyyy}/{mm}/{dd}", DateSource: DateFromName},
+			want:  map[string]string{"out/2024/06/30/export_20240630.csv": "a", "out/2024/07/01/export_20240701.csv": "b"},
+		},
+		{
+			name:  "POS hive layout copied",
+			files: map[string]string{"export_20240630.csv": "a"},
+			opts:  OrganizeOptions{Template: "year={yyyy}/month={mm}", DateSource: DateFromName, Copy: true},
+			want:  map[string]string{"in/export_20240630.csv": "a", "out/year=2024/month=06/export_20240630.csv": "a"},
+		},
+		{
+			name:        "POS undated into fallback",
+			files:       map[string]string{"export_20240630.csv": "a", "readme.txt": "b"},
+			opts:        OrganizeOptions{Template: "{yyyy}", DateSource: DateFromName, Fallback: "undated"},
+			want:        map[string]string{"out/2024/export_20240630.csv": "a", "out/undated/readme.txt": "b"},
+			wantUndated: 1,
+		},
+		{
+			name:        "POS undated left in place",
+			files:       map[string]string{"export_20240630.csv": "a", "readme.txt": "b"},
+			opts:        OrganizeOptions{Template: "{yyyy}", DateSource: DateFromName},
+			want:        map[string]string{"out/2024/export_20240630.csv": "a", "in/readme.txt": "b"},
+			wantUndated: 1,
+		},
+		{
+			name:  "POS dry run",
+			files: map[string]string{"export_20240630.csv": "a"},
+			opts:  OrganizeOptions{Template: "{yyyy}/{mm}", DateSource: DateFromName, DryRun: true},
+			want:  map[string]string{"in/export_20240630.csv": "a"},
+		},
+		{
+			name:    "POS already organized",
+			files:   map[string]string{"2024/06/export_20240630.csv": "a"},
+			inPlace: true,
+			opts:    OrganizeOptions{Template: "{yyyy}/{mm}", DateSource: DateFromName, Conflict: ConflictRename},
+			want:    map[string]string{"in/2024/06/export_20240630.csv": "a"},
+		},
+		{
+			name:    "NEG unknown placeholder",
+			files:   map[string]string{"export_20240630.csv": "a"},
+			opts:    OrganizeOptions{Template: "{yyyy}/{hh}"},
+			want:    map[string]string{"in/export_20240630.csv": "a"},
+			wantErr: true,
+		},
+		{
+			name:    "NEG template leaves destination",
+			files:   map[string]string{"export_20240630.csv": "a"},
+			opts:    OrganizeOptions{Template: "../{yyyy}"},
+			want:    map[string]string{"in/export_20240630.csv": "a"},
+			wantErr: true,
+		},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			src := filepath.Join(root, "in")
+			writeTestTree(t, src, tt.files)
+			destRoot := filepath.Join(root, "out")
+			if tt.inPlace {
+				destRoot = src
+			}
+
+			files, err := ListFiles(src, ListOptions{MaxDepth: UnlimitedDepth})
+			if err != nil {
+				t.Fatalf("ListFiles() error = %v", err)
+			}
+			report, err := OrganizeFiles(files, destRoot, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OrganizeFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := report.Err(); err != nil {
+				t.Errorf("OrganizeFiles() file errors = %v", err)
+			}
+			if report.Undated != tt.wantUndated {
+				t.Errorf("OrganizeFiles() undated = %d, want %d", report.Undated, tt.wantUndated)
+			}
+			if got := readTestTree(t, root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OrganizeFiles() tree = %v, want %v", got, tt.want)
+			}
+			if tt.opts.DryRun {
+				for _, file := range report.Files {
+					if file.Target == "" || file.Action != ActionCreated {
+						t.Errorf("OrganizeFiles() dry run of %s = %q, %v, want a created target", file.Source, file.Target, file.Action)
+					}
+				}
+			}
+		})
+	}
+}
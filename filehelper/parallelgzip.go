@@ -0,0 +1,298 @@
+package filehelper
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"runtime"
+	"time"
+)
+
+const (
+	// DefaultGzipBlockSize is the block size of the parallel gzip writer if none is configured
+	DefaultGzipBlockSize = 1 << 20
+	// gzipDictSize is the size of the deflate window, the tail of every block is used as dictionary for the next one
+	gzipDictSize = 32 * 1024
+)
+
+// GzipOptions holds the settings for gzip compression used by GzipFileWithOptions and for tar.gz archives
+type GzipOptions struct {
+	Level     int  // gzip compression level (gzip.BestSpeed to gzip.BestCompression), 0 for the default
+	Parallel  bool // compress blocks concurrently using a ParallelGzipWriter
+	BlockSize int  // parallel only: uncompressed bytes per block, DefaultGzipBlockSize if 0
+	Workers   int  // parallel only: number of blocks compressed at the same time, GOMAXPROCS if 0
+
+	Name    string    // original file name stored in the gzip header, left out if it is not ISO 8859-1
+	ModTime time.Time // modification time stored in the gzip header, left out if zero
+}
+
+// NewGzipWriter returns a gzip writer for w according to opts: a *gzip.Writer, or a
+// *ParallelGzipWriter if opts.Parallel is set. The output is only complete after Close.
+func NewGzipWriter(w io.Writer, opts GzipOptions) (io.WriteCloser, error) {
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	header := gzip.Header{Name: latin1Name(opts.Name), ModTime: opts.ModTime, OS: 255}
+
+	if opts.Parallel {
+		pw, err := NewParallelGzipWriter(w, level, opts.BlockSize, opts.Workers)
+		if err != nil {
+			return nil, err
+		}
+		pw.Header = header
+		return pw, nil
+	}
+
+	gw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	gw.Header = header
+	return gw, nil
+}
+
+// latin1Name returns name if it can be stored in a gzip header (ISO 8859-1 without NUL),
+// otherwise an empty string, so writing the header cannot fail.
+func latin1Name(name string) string {
+	for _, r := range name {
+		if r == 0 || r > 0xff {
+			return ""
+		}
+	}
+	return name
+}
+
+// gzipBlock is a block of uncompressed data and, once compressed, its deflate output
+type gzipBlock struct {
+	data []byte
+	dict []byte
+	last bool
+	out  bytes.Buffer
+	err  error
+	done chan struct{}
+}
+
+// ParallelGzipWriter is an io.WriteCloser producing gzip output like gzip.Writer, but compressing
+// blocks of the input on several goroutines. Every block is deflated with the tail of the previous
+// block as dictionary and ends on a byte boundary (sync flush), so the blocks form one ordinary
+// deflate stream. The result is a single standard gzip member which every gzip reader can decompress,
+// at a compression ratio slightly below the one of gzip.Writer.
+//
+// Like gzip.Writer, the Header fields are written with the first call to Write or Close.
+type ParallelGzipWriter struct {
+	gzip.Header
+
+	w         io.Writer
+	level     int
+	blockSize int
+	workers   int
+
+	buf         []byte
+	dict        []byte
+	pending     []*gzipBlock
+	crc         uint32
+	size        uint32
+	wroteHeader bool
+	closed      bool
+	err         error
+}
+
+// NewParallelGzipWriter returns a ParallelGzipWriter writing to w. level is a gzip compression level,
+// blockSize the number of uncompressed bytes per block (DefaultGzipBlockSize if 0) and workers the
+// number of blocks compressed at the same time (GOMAXPROCS if 0). Memory use is about
+// 2 * workers * blockSize.
+func NewParallelGzipWriter(w io.Writer, level, blockSize, workers int) (*ParallelGzipWriter, error) {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, errors.New("gzip: invalid compression level")
+	}
+	if blockSize <= 0 {
+		blockSize = DefaultGzipBlockSize
+	}
+	if blockSize < gzipDictSize {
+		blockSize = gzipDictSize
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	return &ParallelGzipWriter{
+		Header:    gzip.Header{OS: 255},
+		w:         w,
+		level:     level,
+		blockSize: blockSize,
+		workers:   workers,
+		buf:       make([]byte, 0, blockSize),
+	}, nil
+}
+
+// Write buffers p and dispatches every full block for compression
+func (z *ParallelGzipWriter) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errors.New("gzip: write to closed writer")
+	}
+
+	z.crc = crc32.Update(z.crc, crc32.IEEETable, p)
+	z.size += uint32(len(p))
+
+	written := 0
+	for len(p) > 0 {
+		n := copy(z.buf[len(z.buf):cap(z.buf)], p)
+		z.buf = z.buf[:len(z.buf)+n]
+		p = p[n:]
+		written += n
+
+		if len(z.buf) == cap(z.buf) {
+			if err := z.dispatch(false); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close compresses the remaining data, waits for all blocks and writes the gzip trailer.
+// It does not close the underlying writer.
+func (z *ParallelGzipWriter) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+
+	if err := z.dispatch(true); err != nil {
+		return err
+	}
+	for len(z.pending) > 0 {
+		if err := z.writeOldest(); err != nil {
+			return err
+		}
+	}
+
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[0:4], z.crc)
+	binary.LittleEndian.PutUint32(trailer[4:8], z.size)
+	_, z.err = z.w.Write(trailer[:])
+	return z.err
+}
+
+// dispatch starts the compression of the buffered block, waiting for the oldest block
+// first if all workers are busy.
+func (z *ParallelGzipWriter) dispatch(last bool) error {
+	for len(z.pending) >= z.workers {
+		if err := z.writeOldest(); err != nil {
+			return err
+		}
+	}
+
+	block := &gzipBlock{data: z.buf, dict: z.dict, last: last, done: make(chan struct{})}
+	z.pending = append(z.pending, block)
+
+	// The tail of this block is the dictionary of the next one
+	if len(z.buf) >= gzipDictSize {
+		z.dict = z.buf[len(z.buf)-gzipDictSize:]
+	} else {
+		z.dict = append(append([]byte{}, z.dict...), z.buf...)
+		if len(z.dict) > gzipDictSize {
+			z.dict = z.dict[len(z.dict)-gzipDictSize:]
+		}
+	}
+	z.buf = make([]byte, 0, z.blockSize)
+
+	go block.compress(z.level)
+	return nil
+}
+
+// writeOldest waits for the oldest pending block and writes its output
+func (z *ParallelGzipWriter) writeOldest() error {
+	block := z.pending[0]
+	z.pending = z.pending[1:]
+	<-block.done
+
+	if block.err != nil {
+		z.err = block.err
+		return z.err
+	}
+	if !z.wroteHeader {
+		z.wroteHeader = true
+		if z.err = z.writeHeader(); z.err != nil {
+			return z.err
+		}
+	}
+	_, z.err = z.w.Write(block.out.Bytes())
+	return z.err
+}
+
+// compress deflates the block, ending with a sync flush or, for the last block, the final deflate block
+func (b *gzipBlock) compress(level int) {
+	defer close(b.done)
+
+	fw, err := flate.NewWriterDict(&b.out, level, b.dict)
+	if err != nil {
+		b.err = err
+		return
+	}
+	if _, err := fw.Write(b.data); err != nil {
+		b.err = err
+		return
+	}
+	if b.last {
+		b.err = fw.Close()
+	} else {
+		b.err = fw.Flush()
+	}
+}
+
+// writeHeader writes the gzip member header including the optional fields of Header
+func (z *ParallelGzipWriter) writeHeader() error {
+	header := []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, z.OS}
+	if z.Extra != nil {
+		header[3] |= 0x04
+	}
+	if z.Name != "" {
+		header[3] |= 0x08
+	}
+	if z.Comment != "" {
+		header[3] |= 0x10
+	}
+	if z.ModTime.After(time.Unix(0, 0)) {
+		binary.LittleEndian.PutUint32(header[4:8], uint32(z.ModTime.Unix()))
+	}
+	switch z.level {
+	case gzip.BestCompression:
+		header[8] = 2
+	case gzip.BestSpeed:
+		header[8] = 4
+	}
+
+	if z.Extra != nil {
+		header = binary.LittleEndian.AppendUint16(header, uint16(len(z.Extra)))
+		header = append(header, z.Extra...)
+	}
+	for _, s := range []string{z.Name, z.Comment} {
+		if s == "" {
+			continue
+		}
+		// The gzip format stores these strings as zero-terminated ISO 8859-1
+		for _, r := range s {
+			if r == 0 || r > 0xff {
+				return errors.New("gzip: non-Latin-1 header string")
+			}
+			header = append(header, byte(r))
+		}
+		header = append(header, 0)
+	}
+
+	_, err := z.w.Write(header)
+	return err
+}
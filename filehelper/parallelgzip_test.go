@@ -0,0 +1,111 @@
+package filehelper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// gzipTestData returns n bytes of moderately compressible data
+func gzipTestData(n int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "2023-06-15", ";", "\n"}
+	var buf bytes.Buffer
+	for buf.Len() < n {
+		buf.WriteString(words[rnd.Intn(len(words))])
+	}
+	return buf.Bytes()[:n]
+}
+
+func TestParallelGzipWriter(t *testing.T) {
+	var tests = []struct {
+		name      string
+		size      int
+		blockSize int
+		workers   int
+	}{
+		{"empty", 0, 0, 0},
+		{"small", 100, 0, 0},
+		{"one block", 64 * 1024, 64 * 1024, 2},
+		{"many blocks", 1<<20 + 123, 64 * 1024, 3},
+		{"single worker", 300 * 1024, 40 * 1024, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := gzipTestData(tt.size)
+
+			var compressed bytes.Buffer
+			zw, err := NewParallelGzipWriter(&compressed, gzip.DefaultCompression, tt.blockSize, tt.workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zw.Name = "data.csv"
+			zw.ModTime = time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC)
+			// Write in odd chunks to cross block boundaries
+			for rest := data; len(rest) > 0; {
+				n := 7777
+				if n > len(rest) {
+					n = len(rest)
+				}
+				if _, err := zw.Write(rest[:n]); err != nil {
+					t.Fatal(err)
+				}
+				rest = rest[n:]
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			zr, err := gzip.NewReader(&compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("got %d bytes, want %d", len(got), len(data))
+			}
+			if zr.Name != "data.csv" || !zr.ModTime.Equal(zw.ModTime) {
+				t.Errorf("got header %q %v", zr.Name, zr.ModTime)
+			}
+		})
+	}
+}
+
+func BenchmarkGzipStdlib(b *testing.B) {
+	data := gzipTestData(32 << 20)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		zw := gzip.NewWriter(io.Discard)
+		if _, err := zw.Write(data); err != nil {
+			b.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGzipParallel(b *testing.B) {
+	data := gzipTestData(32 << 20)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		zw, err := NewParallelGzipWriter(io.Discard, gzip.DefaultCompression, 0, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := zw.Write(data); err != nil {
+			b.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
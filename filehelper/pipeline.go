@@ -0,0 +1,266 @@
+package filehelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PipelineHandler processes one claimed file at path (in the processing directory).
+// Returning an error moves the file to the failed directory.
+type PipelineHandler func(ctx context.Context, path string) error
+
+// PipelineOptions controls a Pipeline. The zero value processes every file of the inbox,
+// one at a time, and never recovers files from the processing directory automatically.
+type PipelineOptions struct {
+	Patterns []string // glob patterns of the file names to process, empty processes all files
+	Workers  int      // number of files handled concurrently, 1 if 0
+
+	// RecoverAfter moves files claimed longer ago than this from processing back to the inbox at the
+	// start of every Run, e.g. after a crash. It has to be longer than the longest handler run.
+	RecoverAfter time.Duration
+}
+
+// PipelineSummary is the result of one Pipeline.Run
+type PipelineSummary struct {
+	Done      []string // paths of the processed files in the done directory
+	Failed    []string // paths of the failed files in the failed directory
+	Recovered []string // paths of the files moved back from processing to the inbox
+	Errors    []error  // errors moving files between the directories, handler errors are in the sidecars
+}
+
+// Err returns the errors of the summary joined into one, or nil if there were none
+func (s PipelineSummary) Err() error {
+	return errors.Join(s.Errors...)
+}
+
+// Pipeline moves files through the directories inbox, processing, done and failed below a root
+// directory. Several processes may run pipelines on the same root concurrently: a file is claimed by
+// moving it from the inbox to processing, which only one of them can do.
+//
+// A failed file is accompanied by a sidecar file with the extension ".error" containing the
+// error message of the handler. The time a file was claimed is recorded in the hidden directory
+// .claims below processing, for Recover.
+type Pipeline struct {
+	Inbox      string
+	Processing string
+	Done       string
+	Failed     string
+	opts       PipelineOptions
+}
+
+// NewPipeline returns the pipeline below root, creating its directories if necessary
+func NewPipeline(root string, opts PipelineOptions) (*Pipeline, error) {
+	p := &Pipeline{
+		Inbox:      filepath.Join(root, "inbox"),
+		Processing: filepath.Join(root, "processing"),
+		Done:       filepath.Join(root, "done"),
+		Failed:     filepath.Join(root, "failed"),
+		opts:       opts,
+	}
+	for _, dir := range []string{p.Inbox, p.Processing, p.Done, p.Failed} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Run claims the files currently in the inbox and calls handler for each of them, with at most
+// opts.Workers at once. Successfully handled files are moved to done, the others to failed with an
+// error sidecar. Files whose name is already in use in done or failed are renamed (see ConflictRename).
+// Once ctx is done, no further files are claimed. The returned error is only set if the inbox
+// cannot be read.
+func (p *Pipeline) Run(ctx context.Context, handler PipelineHandler) (PipelineSummary, error) {
+	var summary PipelineSummary
+
+	if p.opts.RecoverAfter > 0 {
+		recovered, err := p.Recover(p.opts.RecoverAfter)
+		summary.Recovered = recovered
+		if err != nil {
+			summary.Errors = append(summary.Errors, err)
+		}
+	}
+
+	entries, err := os.ReadDir(p.Inbox)
+	if err != nil {
+		return summary, err
+	}
+
+	var tasks []dirTask
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if len(p.opts.Patterns) > 0 && !matchAnyGlob(p.opts.Patterns, entry.Name(), entry.Name()) {
+			continue
+		}
+		tasks = append(tasks, dirTask{
+			src:  filepath.Join(p.Inbox, entry.Name()),
+			dest: filepath.Join(p.Processing, entry.Name()),
+		})
+	}
+
+	workers := p.opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var mu sync.Mutex
+	runWorkers(tasks, workers, func(task dirTask) {
+		if ctx.Err() != nil {
+			return
+		}
+		claimed, err := claimFile(task.src, task.dest)
+		if err != nil || !claimed {
+			if err != nil {
+				mu.Lock()
+				summary.Errors = append(summary.Errors, err)
+				mu.Unlock()
+			}
+			return
+		}
+
+		if err := p.recordClaim(filepath.Base(task.dest), time.Now()); err != nil {
+			mu.Lock()
+			summary.Errors = append(summary.Errors, err)
+			mu.Unlock()
+		}
+
+		handlerErr := handler(ctx, task.dest)
+		path, err := p.finish(task.dest, handlerErr)
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			summary.Errors = append(summary.Errors, err)
+		case handlerErr != nil:
+			summary.Failed = append(summary.Failed, path)
+		default:
+			summary.Done = append(summary.Done, path)
+		}
+	})
+
+	return summary, nil
+}
+
+// Recover moves the files claimed more than minAge ago from processing back to the inbox, so they
+// are processed again. A file without a claim record counts as claimed at the first Recover seeing it. With a minAge of 0, all files are recovered, which is only safe if no other
+// pipeline is running on the same directories. It returns the paths of the files in the inbox.
+func (p *Pipeline) Recover(minAge time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(p.Processing)
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []string
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(p.Processing, entry.Name())
+
+		claimed, err := p.claimTime(entry.Name())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if time.Since(claimed) < minAge {
+			continue
+		}
+
+		target, _, err := MoveFileWithOptions(path, p.Inbox, entry.Name(), CopyOptions{Conflict: ConflictRename})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		recovered = append(recovered, target)
+		if err := p.forgetClaim(entry.Name()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return recovered, errors.Join(errs...)
+}
+
+// finish moves a handled file to done, or to failed together with the error sidecar.
+// It returns the new path of the file.
+func (p *Pipeline) finish(path string, handlerErr error) (string, error) {
+	if handlerErr == nil {
+		target, _, err := MoveFileWithOptions(path, p.Done, filepath.Base(path), CopyOptions{Conflict: ConflictRename})
+		if err != nil {
+			return target, err
+		}
+		return target, p.forgetClaim(filepath.Base(path))
+	}
+
+	target, _, err := MoveFileWithOptions(path, p.Failed, filepath.Base(path), CopyOptions{Conflict: ConflictRename})
+	if err != nil {
+		return path, err
+	}
+	if err := p.forgetClaim(filepath.Base(path)); err != nil {
+		return target, err
+	}
+	message := fmt.Sprintf("%s\n%s\n", time.Now().Format(time.RFC3339), handlerErr)
+	return target, WriteFileAtomic(target+".error", []byte(message), 0644)
+}
+
+// claimFile moves src to dest unless dest exists. It returns false if the file is gone (claimed by
+// another worker) or dest is still in use. An existing dest is never replaced, see renameNoReplace.
+func claimFile(src, dest string) (bool, error) {
+	err := renameNoReplace(src, dest)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, os.ErrExist):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// recordClaim stores the time the file name was claimed at in the claims directory
+func (p *Pipeline) recordClaim(name string, claimed time.Time) error {
+	if err := os.MkdirAll(p.claimsDir(), 0755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(p.claimPath(name), []byte(claimed.Format(time.RFC3339Nano)), 0644)
+}
+
+// claimTime returns the time the file name was claimed at. A file without a record, e.g. after a
+// crash right after claiming it, is recorded as claimed now.
+func (p *Pipeline) claimTime(name string) (time.Time, error) {
+	content, err := os.ReadFile(p.claimPath(name))
+	if os.IsNotExist(err) {
+		now := time.Now()
+		return now, p.recordClaim(name, now)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(string(content)))
+}
+
+// forgetClaim removes the claim record of the file name
+func (p *Pipeline) forgetClaim(name string) error {
+	if err := os.Remove(p.claimPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// claimsDir is the hidden directory below processing holding the claim records
+func (p *Pipeline) claimsDir() string {
+	return filepath.Join(p.Processing, ".claims")
+}
+
+func (p *Pipeline) claimPath(name string) string {
+	return filepath.Join(p.claimsDir(), name)
+}
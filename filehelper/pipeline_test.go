@@ -0,0 +1,178 @@
+package filehelper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClaimFile(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		source  bool // the source file exists
+		dest    bool // the destination file exists
+		claimed bool
+	}{
+		// the table itself
+		{"POS claim", true, false, true},
+		{"NEG already claimed", false, false, false},
+		{"NEG destination in use", true, true, false},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, dest := filepath.Join(dir, "inbox.csv"), filepath.Join(dir, "processing.csv")
+			if tt.source {
+				writeTestTree(t, dir, map[string]string{"inbox.csv": "new"})
+			}
+			if tt.dest {
+				writeTestTree(t, dir, map[string]string{"processing.csv": "old"})
+			}
+
+			claimed, err := claimFile(src, dest)
+			if err != nil || claimed != tt.claimed {
+				t.Fatalf("got %t : %v, want %t", claimed, err, tt.claimed)
+			}
+
+			want := map[string]string{}
+			switch {
+			case tt.claimed:
+				want["processing.csv"] = "new"
+			case tt.dest:
+				want["inbox.csv"], want["processing.csv"] = "new", "old"
+			}
+			if got := readTestTree(t, dir); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPipelineRecover(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name      string
+		minAge    time.Duration
+		recovered []string // names of the files back in the inbox
+	}{
+		// the table itself
+		{"POS old claims", time.Hour, []string{"old.csv"}},
+		{"POS all", 0, []string{"fresh.csv", "old.csv", "unrecorded.csv"}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPipeline(t.TempDir(), PipelineOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTestTree(t, p.Processing, map[string]string{"old.csv": "o", "fresh.csv": "f", "unrecorded.csv": "u"})
+			if err := p.recordClaim("old.csv", time.Now().Add(-2*time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.recordClaim("fresh.csv", time.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			recovered, err := p.Recover(tt.minAge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, path := range recovered {
+				if filepath.Dir(path) != p.Inbox {
+					t.Errorf("got %s, want it in the inbox", path)
+				}
+				names = append(names, filepath.Base(path))
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.recovered) {
+				t.Errorf("got %v, want %v", names, tt.recovered)
+			}
+
+			// The claim records of the recovered files are gone, the others are kept
+			for _, name := range []string{"old.csv", "fresh.csv", "unrecorded.csv"} {
+				_, err := os.Stat(p.claimPath(name))
+				if isRecovered := contains(tt.recovered, name); isRecovered != os.IsNotExist(err) {
+					t.Errorf("%s: got claim record %v, recovered %t", name, err, isRecovered)
+				}
+			}
+		})
+	}
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPipelineRun(t *testing.T) {
+	root := t.TempDir()
+	p, err := NewPipeline(root, PipelineOptions{Patterns: []string{"*.csv"}, Workers: 2, RecoverAfter: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestTree(t, p.Inbox, map[string]string{"a.csv": "a", "bad.csv": "b", "c.csv": "c", "skip.txt": "s"})
+	writeTestTree(t, p.Done, map[string]string{"a.csv": "previous"})
+	writeTestTree(t, p.Processing, map[string]string{"stale.csv": "s"})
+	if err := p.recordClaim("stale.csv", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := p.Run(context.Background(), func(ctx context.Context, path string) error {
+		if filepath.Dir(path) != p.Processing {
+			t.Errorf("got %s, want it in processing", path)
+		}
+		if _, err := os.Stat(p.claimPath(filepath.Base(path))); err != nil {
+			t.Errorf("got no claim record for %s: %v", path, err)
+		}
+		if strings.HasPrefix(filepath.Base(path), "bad") {
+			return errors.New("invalid content")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := summary.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(summary.Done)
+	// The recovered file is processed in the same run
+	if want := []string{filepath.Join(p.Done, "a_1.csv"), filepath.Join(p.Done, "c.csv"), filepath.Join(p.Done, "stale.csv")}; !reflect.DeepEqual(summary.Done, want) {
+		t.Errorf("got done %v, want %v", summary.Done, want)
+	}
+	if want := []string{filepath.Join(p.Failed, "bad.csv")}; !reflect.DeepEqual(summary.Failed, want) {
+		t.Errorf("got failed %v, want %v", summary.Failed, want)
+	}
+	if want := []string{filepath.Join(p.Inbox, "stale.csv")}; !reflect.DeepEqual(summary.Recovered, want) {
+		t.Errorf("got recovered %v, want %v", summary.Recovered, want)
+	}
+	if message, err := os.ReadFile(filepath.Join(p.Failed, "bad.csv.error")); err != nil || !strings.Contains(string(message), "invalid content") {
+		t.Errorf("got error sidecar %q : %v", message, err)
+	}
+
+	// Only the file not matching the patterns is left, the processing directory is empty afterwards
+	if got := readTestTree(t, p.Inbox); !reflect.DeepEqual(got, map[string]string{"skip.txt": "s"}) {
+		t.Errorf("got inbox %v", got)
+	}
+	if got := readTestTree(t, p.Processing); len(got) != 0 {
+		t.Errorf("got processing %v, want it empty", got)
+	}
+}
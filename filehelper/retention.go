@@ -0,0 +1,262 @@
+package filehelper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy describes which files of a directory are kept and which are deleted by ApplyRetention.
+//
+// Files protected by KeepLast or by one of the GFS rules (KeepDaily, KeepWeekly, KeepMonthly) are never
+// deleted by the age rule. If MaxAge is set, only unprotected files older than MaxAge are deleted. If
+// MaxAge is not set, every file not protected by a keep rule is deleted. MaxTotalSize is applied last
+// and deletes the oldest remaining files not protected by KeepLast until the total size fits.
+//
+// Examples:
+//
+//	// delete *.log older than 30 days, but keep the newest 5
+//	RetentionPolicy{Patterns: []string{"*.log"}, MaxAge: 30 * 24 * time.Hour, KeepLast: 5}
+//
+//	// keep one archive per day for 14 days and one per month for a year
+//	RetentionPolicy{Patterns: []string{"*.tar.gz"}, KeepDaily: 14, KeepMonthly: 12}
+type RetentionPolicy struct {
+	Patterns  []string // glob patterns of the files the policy applies to (see ListFiles), empty for all files
+	Recursive bool     // include files in subdirectories
+
+	MaxAge       time.Duration // delete unprotected files older than this
+	KeepLast     int           // always keep the newest N files
+	MaxTotalSize int64         // delete the oldest files until all remaining files fit into this many bytes
+	KeepDaily    int           // keep the newest file of each of the last N days with files
+	KeepWeekly   int           // keep the newest file of each of the last N ISO weeks with files
+	KeepMonthly  int           // keep the newest file of each of the last N months with files
+
+	DateFromName   bool           // date files by the date in their name (stringhelper.ExtractDate) instead of the modification time
+	Location       *time.Location // time zone of the day, week and month boundaries, time.Local if nil
+	Now            time.Time      // reference time for MaxAge, time.Now() if zero
+	DryRun         bool           // only report what would be deleted
+	Trash          *Trash         // move the files into this trash instead of removing them
+	PruneEmptyDirs bool           // remove directories below the root which became empty by the deletion
+}
+
+// RetentionReport is the result of ApplyRetention
+type RetentionReport struct {
+	Kept       []FileInfo // files kept, newest first
+	Deleted    []FileInfo // files deleted (or to be deleted in a dry run), newest first
+	FreedBytes int64      // total size of the deleted files
+	PrunedDirs []string   // empty directories removed (or to be removed in a dry run)
+	Errors     []error    // errors of single deletions, which do not stop the run
+}
+
+// Err returns the errors of the report joined into one, or nil if there were none
+func (r RetentionReport) Err() error {
+	return errors.Join(r.Errors...)
+}
+
+// ApplyRetention applies the policy to the files in dir and deletes the files which are not to be kept.
+// Deletion continues after errors, which are collected in the report. The returned error is only set
+// if the policy is invalid or the directory cannot be listed.
+func ApplyRetention(dir string, policy RetentionPolicy) (RetentionReport, error) {
+	var report RetentionReport
+
+	if policy.MaxAge == 0 && policy.MaxTotalSize == 0 && !policy.hasKeepRules() {
+		return report, errors.New("retention policy has no rules")
+	}
+
+	now := policy.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	loc := policy.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	opts := ListOptions{Patterns: policy.Patterns}
+	if policy.Recursive {
+		opts.MaxDepth = UnlimitedDepth
+	}
+	listed, err := ListFiles(dir, opts)
+	if err != nil {
+		return report, err
+	}
+	var files []FileInfo
+	for _, file := range listed {
+		if !inTrash(file.Path, policy.Trash) {
+			files = append(files, file)
+		}
+	}
+
+	// Newest first
+	dates := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		dates[file.Path] = retentionDate(file, policy.DateFromName, loc)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return dates[files[i].Path].After(dates[files[j].Path])
+	})
+
+	protected := make(map[string]bool)
+	last := make(map[string]bool)
+	for i, file := range files {
+		if i < policy.KeepLast {
+			protected[file.Path], last[file.Path] = true, true
+		}
+	}
+	keepPerPeriod(files, dates, policy.KeepDaily, "2006-01-02", protected)
+	keepPerPeriod(files, dates, policy.KeepWeekly, "", protected)
+	keepPerPeriod(files, dates, policy.KeepMonthly, "2006-01", protected)
+
+	deleted := make(map[string]bool)
+	var remaining int64
+	for _, file := range files {
+		switch {
+		case protected[file.Path]:
+		case policy.MaxAge > 0 && now.Sub(dates[file.Path]) > policy.MaxAge:
+			deleted[file.Path] = true
+		case policy.MaxAge == 0 && policy.hasKeepRules():
+			deleted[file.Path] = true
+		}
+		if !deleted[file.Path] {
+			remaining += file.Size
+		}
+	}
+
+	if policy.MaxTotalSize > 0 {
+		for i := len(files) - 1; i >= 0 && remaining > policy.MaxTotalSize; i-- {
+			if file := files[i]; !deleted[file.Path] && !last[file.Path] {
+				deleted[file.Path] = true
+				remaining -= file.Size
+			}
+		}
+	}
+
+	for _, file := range files {
+		if !deleted[file.Path] {
+			report.Kept = append(report.Kept, file)
+			continue
+		}
+		if !policy.DryRun {
+			if err := removeOrTrash(file.Path, policy.Trash); err != nil {
+				report.Errors = append(report.Errors, err)
+				report.Kept = append(report.Kept, file)
+				delete(deleted, file.Path)
+				continue
+			}
+		}
+		report.Deleted = append(report.Deleted, file)
+		report.FreedBytes += file.Size
+	}
+
+	if policy.PruneEmptyDirs {
+		report.PrunedDirs, err = pruneEmptyDirs(dir, policy.DryRun, deleted, policy.Trash)
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+		}
+	}
+
+	return report, nil
+}
+
+// hasKeepRules reports whether any rule selecting files to keep is set
+func (p RetentionPolicy) hasKeepRules() bool {
+	return p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// retentionDate returns the date of a file used by the retention rules
+func retentionDate(file FileInfo, fromName bool, loc *time.Location) time.Time {
+	source := DateFromModTime
+	if fromName {
+		source = DateFromNameOrModTime
+	}
+	date, _ := fileDate(file, source, loc)
+	return date
+}
+
+// keepPerPeriod protects the newest file of each of the newest n periods. layout formats the period
+// key of a date, an empty layout selects ISO weeks. files have to be sorted newest first.
+func keepPerPeriod(files []FileInfo, dates map[string]time.Time, n int, layout string, protected map[string]bool) {
+	if n <= 0 {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, file := range files {
+		date := dates[file.Path]
+		key := date.Format(layout)
+		if layout == "" {
+			year, week := date.ISOWeek()
+			key = fmt.Sprintf("%d-W%02d", year, week)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		protected[file.Path] = true
+		if len(seen) == n {
+			return
+		}
+	}
+}
+
+// pruneEmptyDirs removes the directories which became empty by the deletion of the deleted files,
+// or would become empty in a dry run, walking upwards from the files to root. Other empty directories
+// and the trash are left alone. It returns the removed directories, deepest first.
+func pruneEmptyDirs(root string, dryRun bool, deleted map[string]bool, trash *Trash) ([]string, error) {
+	root = filepath.Clean(root)
+	candidates := make(map[string]bool)
+	for path := range deleted {
+		for dir := filepath.Dir(path); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+			if inTrash(dir, trash) {
+				break
+			}
+			candidates[dir] = true
+		}
+	}
+
+	// Children have to be handled before their parents, so the deepest directories come first
+	dirs := make([]string, 0, len(candidates))
+	for dir := range candidates {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		di, dj := strings.Count(dirs[i], string(filepath.Separator)), strings.Count(dirs[j], string(filepath.Separator))
+		if di != dj {
+			return di > dj
+		}
+		return dirs[i] < dirs[j]
+	})
+
+	removed := make(map[string]bool)
+	var pruned []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return pruned, err
+		}
+		empty := true
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if !deleted[path] && !removed[path] {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(dir); err != nil {
+				return pruned, err
+			}
+		}
+		removed[dir] = true
+		pruned = append(pruned, dir)
+	}
+
+	return pruned, nil
+}
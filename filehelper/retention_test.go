@@ -0,0 +1,139 @@
+package filehelper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// retentionNow is the reference time of the retention tests, a Sunday
+var retentionNow = time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+
+// retentionFiles are the files of the retention tests by relative path and modification time, newest first
+var retentionFiles = []struct {
+	path    string
+	modTime time.Time
+}{
+	{"d0.log", time.Date(2024, 6, 30, 10, 0, 0, 0, time.UTC)},
+	{"d0b.log", time.Date(2024, 6, 30, 8, 0, 0, 0, time.UTC)},
+	{"d1.log", time.Date(2024, 6, 29, 10, 0, 0, 0, time.UTC)},
+	{"d3.log", time.Date(2024, 6, 27, 10, 0, 0, 0, time.UTC)},
+	{"d10.log", time.Date(2024, 6, 20, 10, 0, 0, 0, time.UTC)},
+	{"d40.log", time.Date(2024, 5, 21, 10, 0, 0, 0, time.UTC)},
+	{"old/d70.log", time.Date(2024, 4, 21, 10, 0, 0, 0, time.UTC)},
+}
+
+// writeRetentionFiles creates retentionFiles below dir, 10 bytes each
+func writeRetentionFiles(t *testing.T, dir string) {
+	t.Helper()
+	for _, file := range retentionFiles {
+		path := filepath.Join(dir, filepath.FromSlash(file.path))
+		writeTestTree(t, dir, map[string]string{file.path: "0123456789"})
+		if err := os.Chtimes(path, file.modTime, file.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// relPaths returns the slash-separated relative paths of the files
+func relPaths(files []FileInfo) []string {
+	paths := []string{}
+	for _, file := range files {
+		paths = append(paths, filepath.ToSlash(file.RelPath))
+	}
+	return paths
+}
+
+func TestApplyRetention(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name   string
+		policy RetentionPolicy
+		kept   []string // relative paths of the kept files, newest first
+		pruned []string // relative paths of the pruned directories
+	}{
+		// the table itself
+		{"POS max age", RetentionPolicy{MaxAge: 5 * 24 * time.Hour},
+			[]string{"d0.log", "d0b.log", "d1.log", "d3.log"}, []string{}},
+		{"POS max age keep last", RetentionPolicy{MaxAge: 5 * 24 * time.Hour, KeepLast: 5},
+			[]string{"d0.log", "d0b.log", "d1.log", "d3.log", "d10.log"}, []string{}},
+		{"POS keep daily", RetentionPolicy{KeepDaily: 3},
+			[]string{"d0.log", "d1.log", "d3.log"}, []string{}},
+		{"POS keep weekly", RetentionPolicy{KeepWeekly: 2},
+			[]string{"d0.log", "d10.log"}, []string{}},
+		{"POS keep daily and monthly", RetentionPolicy{KeepDaily: 2, KeepMonthly: 3},
+			[]string{"d0.log", "d1.log", "d40.log", "old/d70.log"}, []string{}},
+		{"POS keep monthly and max age", RetentionPolicy{KeepMonthly: 2, MaxAge: 5 * 24 * time.Hour},
+			[]string{"d0.log", "d0b.log", "d1.log", "d3.log", "d40.log"}, []string{}},
+		{"POS max total size", RetentionPolicy{MaxTotalSize: 35},
+			[]string{"d0.log", "d0b.log", "d1.log"}, []string{}},
+		{"POS max total size keep last", RetentionPolicy{MaxTotalSize: 15, KeepLast: 2},
+			[]string{"d0.log", "d0b.log"}, []string{}},
+		{"POS prune empty directories", RetentionPolicy{MaxAge: 5 * 24 * time.Hour, PruneEmptyDirs: true},
+			[]string{"d0.log", "d0b.log", "d1.log", "d3.log"}, []string{"old"}},
+		{"POS dry run", RetentionPolicy{KeepLast: 1, PruneEmptyDirs: true, DryRun: true},
+			[]string{"d0.log"}, []string{"old"}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRetentionFiles(t, dir)
+			// An empty directory which existed before is never pruned
+			if err := os.Mkdir(filepath.Join(dir, "drop"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			policy := tt.policy
+			policy.Recursive, policy.Now, policy.Location = true, retentionNow, time.UTC
+
+			report, err := ApplyRetention(dir, policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := report.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if got := relPaths(report.Kept); !reflect.DeepEqual(got, tt.kept) {
+				t.Errorf("got kept %v, want %v", got, tt.kept)
+			}
+			if got, want := len(report.Deleted), len(retentionFiles)-len(tt.kept); got != want || report.FreedBytes != int64(want*10) {
+				t.Errorf("got %d deleted, %d bytes freed, want %d", got, report.FreedBytes, want)
+			}
+			pruned := []string{}
+			for _, path := range report.PrunedDirs {
+				pruned = append(pruned, filepath.ToSlash(strings.TrimPrefix(path, dir+string(filepath.Separator))))
+			}
+			if !reflect.DeepEqual(pruned, tt.pruned) {
+				t.Errorf("got pruned %v, want %v", pruned, tt.pruned)
+			}
+
+			// Only the deleted files are gone, a dry run leaves all of them
+			remaining, err := ListFiles(dir, ListOptions{MaxDepth: UnlimitedDepth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := len(tt.kept)
+			if policy.DryRun {
+				want = len(retentionFiles)
+			}
+			if len(remaining) != want {
+				t.Errorf("got %d files left, want %d", len(remaining), want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "drop")); err != nil {
+				t.Errorf("got %v for the empty directory drop, want it kept", err)
+			}
+			_, err = os.Stat(filepath.Join(dir, "old"))
+			if pruned := len(tt.pruned) > 0 && !policy.DryRun; pruned != os.IsNotExist(err) {
+				t.Errorf("got %v for the directory old, pruned %t", err, pruned)
+			}
+		})
+	}
+
+	if _, err := ApplyRetention(t.TempDir(), RetentionPolicy{}); err == nil {
+		t.Error("got no error for a policy without rules")
+	}
+}
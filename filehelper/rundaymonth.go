@@ -7,12 +7,16 @@ import (
 
 // ShouldRunDayMonth checks if the current day or month is different from the last execution.
 // It checks the timestamp of the last execution stored in the file at the provided path.
-// If the file does not exist, it will be created.
+// If the file does not exist, it will be created. The file is replaced atomically, so a crash
+// never leaves a truncated timestamp behind.
 // The function returns two boolean values indicating whether there was a change in day or month
 // since the last execution, and an error value.
 // If the day or month has changed since the last execution, respective bool will be true.
 // If not, it will be false. If there was an error reading from or writing to the file,
 // the error return value will be non-nil.
+//
+// The new timestamp is written even if the caller's work fails afterwards. Use ScheduleState to
+// commit a run only on success, for other periods and to get the missed periods.
 func ShouldRunDayMonth(dateFilePath string) (runDay bool, runMonth bool, retErr error) {
 
 	// Get the current time
@@ -23,7 +27,7 @@ func ShouldRunDayMonth(dateFilePath string) (runDay bool, runMonth bool, retErr
 	if err != nil {
 		if os.IsNotExist(err) {
 			// If the file does not exist, create it and write the current timestamp
-			err := os.WriteFile(dateFilePath, []byte(now.Format(time.RFC3339)), 0644)
+			err := WriteFileAtomic(dateFilePath, []byte(now.Format(time.RFC3339)), 0644)
 			if err != nil {
 				return false, false, err
 			}
@@ -43,14 +47,15 @@ func ShouldRunDayMonth(dateFilePath string) (runDay bool, runMonth bool, retErr
 		return false, false, err
 	}
 
-	// Check if there was a day change
-	dayChanged := lastExecution.YearDay() != now.YearDay()
+	// Check if there was a day change, comparing whole dates so runs a year apart count as well
+	lastExecution = lastExecution.In(now.Location())
+	dayChanged := !PeriodDay.Start(lastExecution).Equal(PeriodDay.Start(now))
 
 	// Check if there was a month change
-	monthChanged := lastExecution.Month() != now.Month()
+	monthChanged := !PeriodMonth.Start(lastExecution).Equal(PeriodMonth.Start(now))
 
 	// Update the file with the current timestamp
-	if err := os.WriteFile(dateFilePath, []byte(now.Format(time.RFC3339)), 0644); err != nil {
+	if err := WriteFileAtomic(dateFilePath, []byte(now.Format(time.RFC3339)), 0644); err != nil {
 		return dayChanged, monthChanged, err
 	}
 
@@ -0,0 +1,56 @@
+package filehelper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShouldRunDayMonth(t *testing.T) {
+	now := time.Now()
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name     string
+		lastRun  time.Time // zero if the file does not exist
+		runDay   bool
+		runMonth bool
+	}{
+		// the table itself
+		{"POS first run", time.Time{}, false, false},
+		{"POS same day", now, false, false},
+		{"POS exactly one year ago", now.AddDate(-1, 0, 0), true, true},
+		{"POS one month ago", now.AddDate(0, -1, 0), true, true},
+		{"POS start of previous day", PeriodDay.Start(now).AddDate(0, 0, -1), true, PeriodDay.Start(now).Day() == 1},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "lastrun")
+			if !tt.lastRun.IsZero() {
+				if err := os.WriteFile(path, []byte(tt.lastRun.Format(time.RFC3339)), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			runDay, runMonth, err := ShouldRunDayMonth(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if runDay != tt.runDay || runMonth != tt.runMonth {
+				t.Errorf("got %t %t, want %t %t", runDay, runMonth, tt.runDay, tt.runMonth)
+			}
+
+			// The file holds the time of this run afterwards
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if written, err := time.Parse(time.RFC3339, string(content)); err != nil || written.Before(now.Truncate(time.Second)) {
+				t.Errorf("got %q : %v, want the current time", content, err)
+			}
+		})
+	}
+}
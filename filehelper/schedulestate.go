@@ -0,0 +1,294 @@
+package filehelper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Period is a calendar period a job runs once in, see ScheduleState
+type Period int
+
+const (
+	PeriodHour Period = iota
+	PeriodDay
+	PeriodWeek // ISO week, starting on Monday
+	PeriodMonth
+	PeriodQuarter
+	PeriodYear
+)
+
+// String returns the name of the period
+func (p Period) String() string {
+	switch p {
+	case PeriodHour:
+		return "hour"
+	case PeriodDay:
+		return "day"
+	case PeriodWeek:
+		return "week"
+	case PeriodMonth:
+		return "month"
+	case PeriodQuarter:
+		return "quarter"
+	case PeriodYear:
+		return "year"
+	default:
+		return "unknown"
+	}
+}
+
+// Start returns the start of the period containing t, in the location of t
+func (p Period) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	loc := t.Location()
+	switch p {
+	case PeriodHour:
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, loc)
+	case PeriodWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, loc)
+	case PeriodMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	case PeriodQuarter:
+		return time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, loc)
+	case PeriodYear:
+		return time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+}
+
+// Next returns the start of the period following the one containing t
+func (p Period) Next(t time.Time) time.Time {
+	start := p.Start(t)
+	switch p {
+	case PeriodHour:
+		return p.Start(start.Add(time.Hour))
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	case PeriodQuarter:
+		return start.AddDate(0, 3, 0)
+	case PeriodYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Key returns a readable name of the period containing t, e.g. "2024-06-30T13" (hour),
+// "2024-06-30" (day), "2024-W26" (week), "2024-06" (month), "2024-Q2" (quarter) or "2024" (year)
+func (p Period) Key(t time.Time) string {
+	switch p {
+	case PeriodHour:
+		return t.Format("2006-01-02T15")
+	case PeriodWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case PeriodMonth:
+		return t.Format("2006-01")
+	case PeriodQuarter:
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	case PeriodYear:
+		return t.Format("2006")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// DefaultMaxMissed is the number of missed periods Due returns if ScheduleState.MaxMissed is 0
+const DefaultMaxMissed = 100
+
+// DueResult is the answer of ScheduleState.Due
+type DueResult struct {
+	Due     bool      // the job has not run in the current period yet
+	Current time.Time // start of the current period
+	LastRun time.Time // time of the last committed run, zero if the job never ran
+
+	// Missed holds the starts of the periods between the last run and the current one without a
+	// run, oldest first. Only the most recent ScheduleState.MaxMissed periods are listed, the number
+	// of all missed periods is MissedTotal.
+	Missed      []time.Time
+	MissedTotal int
+}
+
+// ScheduleState records the last successful run of jobs in a JSON file, to decide whether a job is due
+// in the current hour, day, week, month, quarter or year. Unlike ShouldRunDayMonth, checking does not
+// change the state: a run is only recorded by Commit, so a failed run is retried next time.
+//
+// Example:
+//
+//	state, err := OpenScheduleState("/var/lib/myjob/schedule.json", location)
+//	result, err := state.Due("export", PeriodMonth, time.Now())
+//	if err == nil && result.Due {
+//		for _, missed := range result.Missed {
+//			// catch up on the month starting at missed
+//		}
+//		if err := runExport(); err == nil {
+//			err = state.Commit("export", time.Now())
+//		}
+//	}
+type ScheduleState struct {
+	// MaxMissed limits the missed periods listed by Due, DefaultMaxMissed if 0, no limit if negative
+	MaxMissed int
+
+	path string
+	loc  *time.Location
+	mu   sync.Mutex
+	runs map[string]time.Time
+}
+
+// scheduleStateFile is the content of the state file
+type scheduleStateFile struct {
+	Jobs map[string]scheduleJobState `json:"jobs"`
+}
+
+type scheduleJobState struct {
+	LastRun time.Time `json:"last_run"`
+}
+
+// OpenScheduleState reads the state file at path, which need not exist yet. The periods are
+// computed in loc, time.Local if nil.
+func OpenScheduleState(path string, loc *time.Location) (*ScheduleState, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &ScheduleState{path: path, loc: loc}
+	runs, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.runs = runs
+	return s, nil
+}
+
+// Due reports whether job has to run in the period containing now and which earlier periods were
+// missed since its last run. A job which never ran is due without missed periods. The state file is
+// read again under its lock, so runs committed by other processes are taken into account.
+func (s *ScheduleState) Due(job string, period Period, now time.Time) (DueResult, error) {
+	result := DueResult{Current: period.Start(now.In(s.loc))}
+	if err := s.reload(); err != nil {
+		return result, err
+	}
+
+	s.mu.Lock()
+	lastRun, ok := s.runs[job]
+	s.mu.Unlock()
+	if !ok {
+		result.Due = true
+		return result, nil
+	}
+
+	result.LastRun = lastRun
+	lastStart := period.Start(lastRun.In(s.loc))
+	result.Due = lastStart.Before(result.Current)
+	if !result.Due {
+		return result, nil
+	}
+
+	maxMissed := s.MaxMissed
+	if maxMissed == 0 {
+		maxMissed = DefaultMaxMissed
+	}
+	for start := period.Next(lastStart); start.Before(result.Current); start = period.Next(start) {
+		result.MissedTotal++
+		result.Missed = append(result.Missed, start)
+		if maxMissed > 0 && len(result.Missed) > maxMissed {
+			result.Missed = result.Missed[1:]
+		}
+	}
+	return result, nil
+}
+
+// reload reads the state file under its lock. The lock is released before the runs are replaced,
+// as Commit takes the lock while holding mu.
+func (s *ScheduleState) reload() error {
+	runs, err := func() (map[string]time.Time, error) {
+		lock, err := Lock(s.path + ".lock")
+		if err != nil {
+			return nil, err
+		}
+		defer func(lock *FileLock) {
+			_ = lock.Unlock()
+		}(lock)
+		return s.read()
+	}()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.runs = runs
+	s.mu.Unlock()
+	return nil
+}
+
+// LastRun returns the time of the last committed run of job, false if it never ran
+func (s *ScheduleState) LastRun(job string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lastRun, ok := s.runs[job]
+	return lastRun, ok
+}
+
+// Commit records a successful run of job at runTime and writes the state file. Changes of other
+// jobs written to the file in the meantime are kept; the file is locked while it is updated
+// (see Lock) and replaced atomically.
+func (s *ScheduleState) Commit(job string, runTime time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, err := Lock(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer func(lock *FileLock) {
+		_ = lock.Unlock()
+	}(lock)
+
+	runs, err := s.read()
+	if err != nil {
+		return err
+	}
+	runs[job] = runTime
+
+	state := scheduleStateFile{Jobs: make(map[string]scheduleJobState, len(runs))}
+	for name, lastRun := range runs {
+		state.Jobs[name] = scheduleJobState{LastRun: lastRun}
+	}
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(s.path, content, 0644); err != nil {
+		return err
+	}
+
+	s.runs = runs
+	return nil
+}
+
+// read reads the last runs from the state file, a missing file gives an empty state
+func (s *ScheduleState) read() (map[string]time.Time, error) {
+	runs := make(map[string]time.Time)
+	content, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return runs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state scheduleStateFile
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("invalid schedule state %s: %w", s.path, err)
+	}
+	for name, job := range state.Jobs {
+		runs[name] = job.LastRun
+	}
+	return runs, nil
+}
@@ -0,0 +1,121 @@
+package filehelper
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduleStateDue(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2024, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name      string
+		lastRun   time.Time // zero if the job never ran
+		period    Period
+		maxMissed int
+		due       bool
+		missed    []time.Time
+		total     int
+	}{
+		// the table itself
+		{"POS never ran", time.Time{}, PeriodDay, 0, true, nil, 0},
+		{"POS ran today", now.Add(-time.Hour), PeriodDay, 0, false, nil, 0},
+		{"POS ran yesterday", now.Add(-24 * time.Hour), PeriodDay, 0, true, nil, 0},
+		{"POS missed days", day(6, 27), PeriodDay, 0, true, []time.Time{day(6, 28), day(6, 29)}, 2},
+		{"POS missed months", day(3, 15), PeriodMonth, 0, true, []time.Time{day(4, 1), day(5, 1)}, 2},
+		{"POS ran this week", day(6, 24), PeriodWeek, 0, false, nil, 0},
+		{"POS missed week", day(6, 14), PeriodWeek, 0, true, []time.Time{day(6, 17)}, 1},
+		{"POS missed capped", day(6, 20), PeriodDay, 3, true, []time.Time{day(6, 27), day(6, 28), day(6, 29)}, 9},
+		{"POS missed default cap", now.AddDate(-1, 0, 0), PeriodDay, 0, true, nil, 365},
+		{"POS missed no cap", now.AddDate(-1, 0, 0), PeriodDay, -1, true, nil, 365},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := OpenScheduleState(filepath.Join(t.TempDir(), "schedule.json"), time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state.MaxMissed = tt.maxMissed
+			if !tt.lastRun.IsZero() {
+				if err := state.Commit("export", tt.lastRun); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := state.Due("export", tt.period, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Due != tt.due || result.MissedTotal != tt.total || !result.LastRun.Equal(tt.lastRun) {
+				t.Errorf("got due %t, %d missed, last run %s", result.Due, result.MissedTotal, result.LastRun)
+			}
+			if !result.Current.Equal(tt.period.Start(now)) {
+				t.Errorf("got current %s, want %s", result.Current, tt.period.Start(now))
+			}
+
+			wantMissed := tt.missed
+			switch {
+			case tt.total > 0 && tt.missed == nil && tt.maxMissed == 0:
+				if len(result.Missed) != DefaultMaxMissed || !result.Missed[DefaultMaxMissed-1].Equal(day(6, 29)) {
+					t.Errorf("got %d missed, want the last %d", len(result.Missed), DefaultMaxMissed)
+				}
+				return
+			case tt.total > 0 && tt.missed == nil:
+				if len(result.Missed) != tt.total {
+					t.Errorf("got %d missed, want %d", len(result.Missed), tt.total)
+				}
+				return
+			}
+			if len(result.Missed) != len(wantMissed) {
+				t.Fatalf("got missed %v, want %v", result.Missed, wantMissed)
+			}
+			for i := range wantMissed {
+				if !result.Missed[i].Equal(wantMissed[i]) {
+					t.Errorf("got missed %v, want %v", result.Missed, wantMissed)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleStateShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	first, err := OpenScheduleState(path, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := OpenScheduleState(path, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A run committed by another instance (e.g. another process) is seen by Due
+	if err := first.Commit("export", now); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := second.Due("export", PeriodDay, now); err != nil || result.Due {
+		t.Errorf("got %+v : %v, want not due", result, err)
+	}
+
+	// Commit keeps the jobs committed by the other instance
+	if err := second.Commit("import", now); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenScheduleState(path, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range []string{"export", "import"} {
+		if lastRun, ok := reopened.LastRun(job); !ok || !lastRun.Equal(now) {
+			t.Errorf("%s: got %s %t, want %s", job, lastRun, ok, now)
+		}
+	}
+}
@@ -2,17 +2,22 @@ package filehelper
 
 import (
 	"archive/tar"
-	"compress/gzip"
+	"bufio"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/smithyat/go-helpers/logger"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // ExtractAllTarGzInDirectory reads the specified 'srcDir' and for every .tar.gz file found,
-// it extracts the file to the 'destDir'. It logs any error encountered during the file operations
+// it extracts the file to the 'destDir' and removes the .tar.gz file after a successful extraction.
+// Other tar archives are only extracted with ExtractAllOptions.AllTarArchives. It logs any error encountered during the file operations
 // such as opening a file, extracting, and removing a file via the provided logrus Logger.
 // It does not look into any subdirectories of 'srcDir'.
 //
@@ -25,39 +30,73 @@ import (
 // Note: This function does not return any value. All the errors are logged and the function
 // moves to the next file operation when an error is encountered.
 func ExtractAllTarGzInDirectory(srcDir, destDir string, logPtr *logrus.Entry) {
-	files, err := os.ReadDir(srcDir)
-	if err != nil {
-		// Handle error if needed, e.g. srcDir does not exist
-		logPtr.Errorf("failed to read directory %s: %v [%s]", srcDir, err, logger.Trace())
-		return
+	ExtractAllTarGzInDirectoryWithOptions(srcDir, destDir, ExtractAllOptions{}, logPtr)
+}
+
+// ExtractAllOptions controls ExtractAllTarGzInDirectoryWithOptions.
+// The zero value extracts the .tar.gz files directly in srcDir and deletes them permanently.
+type ExtractAllOptions struct {
+	Recursive bool // also extract the archives in subdirectories of srcDir
+
+	// AllTarArchives extracts (and removes) every tar archive accepted by IsTarArchive, e.g. .tar,
+	// .tgz, .tar.zst or .tar.xz, not only .tar.gz files
+	AllTarArchives bool
+
+	Extract ExtractOptions // limits and policies of the extraction of each archive
+	Trash   *Trash         // move the extracted archives into this trash instead of removing them
+}
+
+// ExtractAllTarGzInDirectoryWithOptions works like ExtractAllTarGzInDirectory, or like
+// ExtractAllTarGzInDirectoryRecursive with opts.Recursive, and applies the extraction options to
+// every archive. With opts.Trash, the extracted archives are moved into the trash. logPtr can be nil.
+func ExtractAllTarGzInDirectoryWithOptions(srcDir, destDir string, opts ExtractAllOptions, logPtr *logrus.Entry) {
+	if logPtr == nil {
+		logPtr = logrus.NewEntry(logrus.New())
+		logPtr.Logger.SetOutput(io.Discard)
 	}
 
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".tar.gz") {
-			path := filepath.Join(srcDir, file.Name())
-			tarGzFile, err := os.Open(path)
-			if err != nil {
-				logPtr.Errorf("failed to open file %s: %v [%s]", path, err, logger.Trace())
-				continue
+	_ = filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			logPtr.Errorf("failed to walk path %s: %v [%s]", path, err, logger.Trace())
+			if path == srcDir {
+				return err
 			}
+			return nil
+		}
 
-			logPtr.Infof("extracting file %s", path)
-			err = ExtractTarGz(tarGzFile, destDir)
-			_ = tarGzFile.Close()
-
-			if err != nil {
-				logPtr.Errorf("failed to extract file %s: %v [%s]", path, err, logger.Trace())
-				continue
+		if d.IsDir() {
+			if path != srcDir && (!opts.Recursive || inTrash(path, opts.Trash)) {
+				return filepath.SkipDir
 			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".tar.gz") && !(opts.AllTarArchives && IsTarArchive(d.Name())) {
+			return nil
+		}
+
+		tarGzFile, err := os.Open(path)
+		if err != nil {
+			logPtr.Errorf("failed to open file %s: %v [%s]", path, err, logger.Trace())
+			return nil
+		}
 
-			logPtr.Infof("extracted file %s", path)
+		logPtr.Infof("extracting file %s", path)
+		err = ExtractTarWithOptions(tarGzFile, destDir, opts.Extract)
+		_ = tarGzFile.Close()
 
-			err = os.Remove(path)
-			if err != nil {
-				logPtr.Errorf("failed to remove file %s: %v [%s]", path, err, logger.Trace())
-			}
+		if err != nil {
+			logPtr.Errorf("failed to extract file %s: %v [%s]", path, err, logger.Trace())
+			return nil
 		}
-	}
+
+		logPtr.Infof("extracted file %s", path)
+
+		err = removeOrTrash(path, opts.Trash)
+		if err != nil {
+			logPtr.Errorf("failed to remove file %s: %v [%s]", path, err, logger.Trace())
+		}
+		return nil
+	})
 }
 
 // ExtractAllTarGzInDirectoryRecursive walks through the provided source directory
@@ -86,47 +125,7 @@ func ExtractAllTarGzInDirectory(srcDir, destDir string, logPtr *logrus.Entry) {
 // Example:
 // ExtractAllTarGzInDirectoryRecursive("/path/to/src", "/path/to/dest", logrus.NewEntry(logger))
 func ExtractAllTarGzInDirectoryRecursive(srcDir, destDir string, logPtr *logrus.Entry) {
-	_ = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			if logPtr != nil {
-				logPtr.Errorf("failed to walk path %s: %v [%s]", path, err, logger.Trace())
-			}
-			return nil
-		}
-
-		if strings.HasSuffix(info.Name(), ".tar.gz") {
-			tarGzFile, err := os.Open(path)
-			if err != nil {
-				if logPtr != nil {
-					logPtr.Errorf("failed to open file %s: %v [%s]", path, err, logger.Trace())
-				}
-				return nil
-			}
-			defer func(targzfile *os.File) {
-				_ = tarGzFile.Close()
-			}(tarGzFile)
-
-			logger.Log.Infof("extracting file %s", path)
-			err = ExtractTarGz(tarGzFile, destDir)
-			if err != nil {
-				if logPtr != nil {
-					logPtr.Errorf("failed to extract file %s: %v [%s]", path, err, logger.Trace())
-				}
-				return nil
-			}
-			logger.Log.Infof("extracted file %s", path)
-
-			err = os.Remove(path)
-			if err != nil {
-				if logPtr != nil {
-					logPtr.Errorf("failed to remove file %s: %v [%s]", path, err, logger.Trace())
-				}
-				return nil
-			}
-
-		}
-		return nil
-	})
+	ExtractAllTarGzInDirectoryWithOptions(srcDir, destDir, ExtractAllOptions{Recursive: true}, logPtr)
 }
 
 // ExtractTarGz takes an io.Reader representing a compressed tarball and a destination string representing
@@ -136,25 +135,93 @@ func ExtractAllTarGzInDirectoryRecursive(srcDir, destDir string, logPtr *logrus.
 // It returns an error if there is any issue during the decompression or extraction process, such as an issue
 // creating directories or files, or a problem with the tar archive itself.
 //
-// The extraction process strictly adheres to the structure of the tarball - directories are created in the 'dest'
-// directory as needed, and individual files are written to these directories. The file permissions are also
-// maintained during the extraction process.
+// The extraction process strictly adheres to the structure of the tarball - directories, files, symbolic links
+// and hard links are created in the 'dest' directory, missing parent directories are created as needed and
+// existing files are replaced. File permissions and modification times are maintained during the extraction process.
+//
+// Entries with absolute paths, entries leaving 'dest' via "..", links pointing outside of 'dest' and entries
+// which would be written through an existing symlink leading outside of 'dest' are rejected with an
+// *UnsafeEntryError. No size limits are applied, use ExtractTarGzWithOptions for archives from untrusted sources.
 //
-// Note: This function does not handle symbolic links, block devices, or other less common file types in the tar archive.
+// Note: Block devices, fifos and other less common file types in the tar archive are skipped.
 func ExtractTarGz(gzipStream io.Reader, dest string) error {
-	uncompressedStream, err := gzip.NewReader(gzipStream)
+	return ExtractTarGzWithOptions(gzipStream, dest, ExtractOptions{})
+}
+
+// ExtractTarGzWithOptions works like ExtractTarGz, but lets opts control how entries are written
+// (link handling, overwrite policy, metadata restoration, per-entry callback) and enforces the limits
+// of opts on the number of entries, the total number of extracted bytes and the compression ratio.
+// When a limit is exceeded, extraction stops with an *UnsafeEntryError naming the offending entry.
+// Files extracted before an error are left in place.
+//
+// Despite its name, the function accepts every format ExtractTarWithOptions does.
+func ExtractTarGzWithOptions(gzipStream io.Reader, dest string, opts ExtractOptions) error {
+	return ExtractTarWithOptions(gzipStream, dest, opts)
+}
+
+// ExtractTar extracts a tar archive into dest like ExtractTarGz. The archive may be uncompressed
+// or compressed with any codec of this package (gzip, zstd, bzip2, xz), which is detected from the
+// magic bytes of the stream, so .tar, .tar.gz, .tgz, .tar.zst, .tar.bz2 and .tar.xz files all work.
+func ExtractTar(r io.Reader, dest string) error {
+	return ExtractTarWithOptions(r, dest, ExtractOptions{})
+}
+
+// ExtractTarWithOptions works like ExtractTar with the options described in ExtractTarGzWithOptions.
+// For uncompressed archives, the compression ratio limit does not apply.
+func ExtractTarWithOptions(r io.Reader, dest string, opts ExtractOptions) error {
+	guard, compressed := newExtractGuard(r, opts)
+
+	br := bufio.NewReader(compressed)
+	codec, err := DetectCodec(br)
+	if err == ErrUnknownFormat {
+		// Not compressed, the input is read as plain tar stream
+		guard.compressed = nil
+		return extractTar(br, dest, opts, guard)
+	}
+	if err != nil {
+		return err
+	}
+
+	uncompressedStream, err := codec.NewReader(br)
 	if err != nil {
 		return err
 	}
+	defer func(uncompressedStream io.ReadCloser) {
+		_ = uncompressedStream.Close()
+	}(uncompressedStream)
+
+	return extractTar(uncompressedStream, dest, opts, guard)
+}
 
-	tarReader := tar.NewReader(uncompressedStream)
+// tarSuffixes are the file name suffixes of the tar archives ExtractTar can read
+var tarSuffixes = []string{".tar", ".tar.gz", ".tgz", ".tar.zst", ".tzst", ".tar.bz2", ".tbz2", ".tar.xz", ".txz"}
+
+// IsTarArchive reports whether the file name has the suffix of a (compressed) tar archive
+func IsTarArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range tarSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractTar extracts the uncompressed tar stream into dest
+func extractTar(r io.Reader, dest string, opts ExtractOptions, guard *extractGuard) error {
+	x, err := newExtractor(dest, opts, guard)
+	if err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(r)
 
 	for {
 		header, err := tarReader.Next()
 
 		switch {
 		case err == io.EOF:
-			return nil
+			return x.finish()
 
 		case err != nil:
 			return err
@@ -163,29 +230,38 @@ func ExtractTarGz(gzipStream io.Reader, dest string) error {
 			continue
 		}
 
-		target := filepath.Join(dest, header.Name)
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if _, err := os.Stat(target); err != nil {
-				if err := os.MkdirAll(target, 0755); err != nil {
-					return err
-				}
-			}
-
-		case tar.TypeReg:
-			file, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return err
-			}
+		if err := x.extract(tarEntry(header), tarReader); err != nil {
+			return err
+		}
+	}
+}
 
-			if _, err := io.Copy(file, tarReader); err != nil {
-				return err
-			}
+// tarEntry converts a tar header into an ArchiveEntry
+func tarEntry(header *tar.Header) ArchiveEntry {
+	entry := ArchiveEntry{
+		Name:     header.Name,
+		Size:     header.Size,
+		Mode:     header.FileInfo().Mode(),
+		ModTime:  header.ModTime,
+		Linkname: header.Linkname,
+		Uid:      header.Uid,
+		Gid:      header.Gid,
+	}
 
-			_ = file.Close()
-		}
+	switch header.Typeflag {
+	case tar.TypeReg:
+		entry.Type = EntryFile
+	case tar.TypeDir:
+		entry.Type = EntryDir
+	case tar.TypeSymlink:
+		entry.Type = EntrySymlink
+	case tar.TypeLink:
+		entry.Type = EntryHardlink
+	default:
+		entry.Type = EntryOther
 	}
+
+	return entry
 }
 
 // CreateTarGz is a function that creates a .tar.gz archive from a given source directory.
@@ -193,61 +269,153 @@ func ExtractTarGz(gzipStream io.Reader, dest string) error {
 // and a relative path for the file (`relPath`) as arguments.
 // If the relative path is not provided, it uses the same directory as the file for it.
 // The function walks through every file in the source directory, generates a tar header for each file and writes it to the tar.gz file.
-// If the file is not a directory, it writes the content of the file to the archive.
-// After successfully writing the file to the archive, it deletes the original file.
+// If the file is not a directory, it streams the content of the file to the archive.
+// The source files are kept, use CreateTarGzWithOptions with RemoveSources to delete them after archiving.
+// The archive is written atomically (see AtomicWriter), so it never exists with partial content.
 // This function returns an error if any occurs during the process.
 func CreateTarGz(myTarGzFile, mySourcePath, relPath string) error {
-	tarGzFile, err := os.Create(myTarGzFile)
-	defer func(targzfile *os.File) {
-		_ = tarGzFile.Close()
-	}(tarGzFile)
+	return CreateTarGzWithOptions(myTarGzFile, mySourcePath, relPath, ArchiveOptions{})
+}
+
+// CreateTarGzWithOptions works like CreateTarGz, but lets opts select the files to archive, control
+// symlink handling and header normalization, and optionally remove the sources.
+//
+// Entries are written in lexical order. With opts.Reproducible, owner names and ids as well as access
+// and change times are dropped and modification times are truncated to seconds (or replaced by
+// opts.ModTime), so archiving an unchanged tree twice yields identical archives.
+//
+// With opts.RemoveSources, the archived files (not the directories) are removed only after the archive
+// has been flushed to disk and read back completely without error.
+func CreateTarGzWithOptions(myTarGzFile, mySourcePath, relPath string, opts ArchiveOptions) error {
+	files, err := collectArchiveFiles(mySourcePath, relPath, myTarGzFile, opts)
+	if err != nil {
+		return err
+	}
+
+	// The archive only appears once it is complete
+	tarGzFile, err := NewAtomicWriter(myTarGzFile, 0644)
+	if err != nil {
+		return err
+	}
+	defer tarGzFile.Abort()
+
+	if err := writeTarGz(tarGzFile, files, opts); err != nil {
+		return err
+	}
+	if err := tarGzFile.Close(); err != nil {
+		return err
+	}
+
+	if !opts.RemoveSources {
+		return nil
+	}
+
+	// A damaged archive is removed, so it is not mistaken for a valid one
+	report, err := VerifyTarGz(myTarGzFile)
+	if err == nil && report.Entries != archiveEntryCount(files, opts) {
+		err = fmt.Errorf("verification of %s failed: %d entries found, %d expected", myTarGzFile, report.Entries, archiveEntryCount(files, opts))
+	}
+	if err != nil {
+		_ = os.Remove(myTarGzFile)
+		return err
+	}
 
-	gw := gzip.NewWriter(tarGzFile)
-	defer func(gw *gzip.Writer) {
-		_ = gw.Close()
-	}(gw)
+	return removeArchivedFiles(files, opts.Trash)
+}
+
+// writeTarGz writes the files as a gzip compressed tar stream to w
+func writeTarGz(w io.Writer, files []archiveFile, opts ArchiveOptions) error {
+	manifest, err := newManifestWriter(files, opts)
+	if err != nil {
+		return err
+	}
 
+	gw, err := NewGzipWriter(w, opts.Gzip)
+	if err != nil {
+		return err
+	}
 	tw := tar.NewWriter(gw)
-	defer func(tw *tar.Writer) {
-		_ = tw.Close()
-	}(tw)
-
-	// Walk through every file in the folder
-	err = filepath.Walk(mySourcePath, func(file string, fi os.FileInfo, err error) error {
-		// Generate tar header
-		header, err := tar.FileInfoHeader(fi, fi.Name())
-		if err != nil {
-			return nil
-		}
 
-		var relativeFilePath string
-		if relPath == "" {
-			relativeFilePath, _ = filepath.Rel(mySourcePath, file) // Set relative path to the same directory as the file
-		} else {
-			relativeFilePath, _ = filepath.Rel(relPath, file) // Set a specific relative path
+	for _, file := range files {
+		h := manifest.hash()
+		if err := writeTarEntry(tw, file, opts, h); err != nil {
+			return err
 		}
-		header.Name = relativeFilePath
+		if file.info.Mode().IsRegular() {
+			manifest.add(file.name, h)
+		}
+	}
 
-		// Write header
+	if manifest != nil {
+		content := manifest.content()
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     opts.Manifest.ManifestName(),
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  manifestModTime(files, opts),
+		}
+		if opts.Reproducible {
+			header.Format = tar.FormatPAX
+		}
 		if err := tw.WriteHeader(header); err != nil {
-			return nil
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
 		}
+	}
 
-		// If it's not a directory, write file content
-		if !fi.Mode().IsDir() {
-			data, err := os.ReadFile(file)
-			if err != nil {
-				return nil
-			}
-			if _, err := tw.Write(data); err != nil {
-				return nil
-			}
-			// Delete the file after archiving
-			if err := os.Remove(file); err != nil {
-				return nil
-			}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// writeTarEntry writes the header and, for regular files, the streamed content of file.
+// If h is not nil, the content is hashed as well.
+func writeTarEntry(tw *tar.Writer, file archiveFile, opts ArchiveOptions, h hash.Hash) error {
+	header, err := tar.FileInfoHeader(file.info, file.linkname)
+	if err != nil {
+		return err
+	}
+	header.Name = file.name
+
+	if opts.Reproducible {
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
+		header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+		header.ModTime = header.ModTime.Truncate(time.Second)
+		if !opts.ModTime.IsZero() {
+			header.ModTime = opts.ModTime.Truncate(time.Second)
 		}
+		header.Format = tar.FormatPAX
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if header.Typeflag != tar.TypeReg {
 		return nil
-	})
-	return err
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	var w io.Writer = tw
+	if h != nil {
+		w = io.MultiWriter(tw, h)
+	}
+
+	n, err := io.CopyN(w, f, header.Size)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s (%d of %d bytes written): %w", file.path, n, header.Size, err)
+	}
+	return nil
 }
@@ -0,0 +1,299 @@
+package filehelper
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// tarGzEntry describes an entry of a test archive
+type tarGzEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	content  []byte
+}
+
+// buildTarGz creates an in-memory tar.gz archive of the entries
+func buildTarGz(t *testing.T, entries []tarGzEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0640, Size: int64(len(e.content)),
+			ModTime: time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractTarGzUnsafe(t *testing.T) {
+	var tests = []struct {
+		name    string
+		entries []tarGzEntry
+		opts    ExtractOptions
+		want    error
+	}{
+		{"POS plain", []tarGzEntry{{name: "dir/", typeflag: tar.TypeDir}, {name: "dir/a.txt", typeflag: tar.TypeReg, content: []byte("a")}}, ExtractOptions{}, nil},
+		{"NEG traversal", []tarGzEntry{{name: "../evil.txt", typeflag: tar.TypeReg, content: []byte("x")}}, ExtractOptions{}, ErrPathTraversal},
+		{"NEG nested traversal", []tarGzEntry{{name: "dir/../../evil.txt", typeflag: tar.TypeReg}}, ExtractOptions{}, ErrPathTraversal},
+		{"NEG absolute", []tarGzEntry{{name: "/tmp/evil.txt", typeflag: tar.TypeReg}}, ExtractOptions{}, ErrAbsolutePath},
+		{"NEG symlink", []tarGzEntry{{name: "link", typeflag: tar.TypeSymlink, linkname: "../../etc"}}, ExtractOptions{}, ErrLinkEscape},
+		{"NEG absolute symlink", []tarGzEntry{{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc"}}, ExtractOptions{}, ErrLinkEscape},
+		{"NEG symlink chain", []tarGzEntry{{name: "l1", typeflag: tar.TypeSymlink, linkname: "."}, {name: "l1/l2", typeflag: tar.TypeSymlink, linkname: "../outside"}}, ExtractOptions{}, ErrLinkEscape},
+		{"NEG symlink through link", []tarGzEntry{{name: "l1", typeflag: tar.TypeSymlink, linkname: "."}, {name: "l3", typeflag: tar.TypeSymlink, linkname: "l1/.."}}, ExtractOptions{}, ErrLinkEscape},
+		{"POS symlink inside", []tarGzEntry{{name: "dir/", typeflag: tar.TypeDir}, {name: "dir/link", typeflag: tar.TypeSymlink, linkname: "../dir"}}, ExtractOptions{}, nil},
+		{"NEG hardlink", []tarGzEntry{{name: "link", typeflag: tar.TypeLink, linkname: "../x"}}, ExtractOptions{}, ErrLinkEscape},
+		{"NEG files", []tarGzEntry{{name: "a", typeflag: tar.TypeReg}, {name: "b", typeflag: tar.TypeReg}}, ExtractOptions{MaxFiles: 1}, ErrTooManyFiles},
+		{"NEG size", []tarGzEntry{{name: "a", typeflag: tar.TypeReg, content: make([]byte, 100)}}, ExtractOptions{MaxTotalBytes: 99}, ErrTooLarge},
+		{"NEG ratio", []tarGzEntry{{name: "a", typeflag: tar.TypeReg, content: make([]byte, 4<<20)}}, ExtractOptions{MaxRatio: 100}, ErrCompressionRatio},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "dest")
+			err := ExtractTarGzWithOptions(bytes.NewReader(buildTarGz(t, tt.entries)), dest, tt.opts)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want nil", err)
+				}
+				return
+			}
+			var entryErr *UnsafeEntryError
+			if !errors.As(err, &entryErr) || !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractTarGzExistingSymlink(t *testing.T) {
+	base := t.TempDir()
+	dest := filepath.Join(base, "dest")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{dest, outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(dest, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := buildTarGz(t, []tarGzEntry{{name: "link/evil.txt", typeflag: tar.TypeReg, content: []byte("x")}})
+	err := ExtractTarGz(bytes.NewReader(archive), dest)
+	if !errors.Is(err, ErrLinkEscape) {
+		t.Fatalf("got %v, want %v", err, ErrLinkEscape)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file written outside of destination")
+	}
+}
+
+func TestExtractTarGzFidelity(t *testing.T) {
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "a.txt"), []byte("a much longer old content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := buildTarGz(t, []tarGzEntry{
+		{name: "a.txt", typeflag: tar.TypeReg, content: []byte("new")},
+		{name: "sub/deep/b.txt", typeflag: tar.TypeReg, content: []byte("b")},
+		{name: "sub/link", typeflag: tar.TypeSymlink, linkname: "deep/b.txt"},
+		{name: "hard", typeflag: tar.TypeLink, linkname: "a.txt"},
+	})
+
+	var actions []EntryAction
+	opts := ExtractOptions{OnEntry: func(entry ArchiveEntry, target string, action EntryAction) {
+		actions = append(actions, action)
+	}}
+	if err := ExtractTarGzWithOptions(bytes.NewReader(archive), dest, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{"a.txt": "new", "sub/link": "b", "hard": "new"} {
+		got, err := os.ReadFile(filepath.Join(dest, path))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, want %q : %v", path, got, want, err)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dest, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 || !info.ModTime().Equal(time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("got mode %v and mtime %v", info.Mode(), info.ModTime())
+	}
+	if want := []EntryAction{ActionOverwritten, ActionCreated, ActionCreated, ActionCreated}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("got actions %v, want %v", actions, want)
+	}
+
+	// Extracting again with ConflictRename keeps the existing files
+	opts = ExtractOptions{Overwrite: ConflictRename}
+	if err := ExtractTarGzWithOptions(bytes.NewReader(archive), dest, opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "a_1.txt")); err != nil {
+		t.Errorf("renamed file missing: %v", err)
+	}
+}
+
+func TestExtractTarGzDirConflict(t *testing.T) {
+	archive := buildTarGz(t, []tarGzEntry{
+		{name: "data/", typeflag: tar.TypeDir},
+		{name: "data/a.txt", typeflag: tar.TypeReg, content: []byte("a")},
+		{name: "data/sub/", typeflag: tar.TypeDir},
+		{name: "data/sub/b.txt", typeflag: tar.TypeReg, content: []byte("b")},
+	})
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name        string
+		policy      ConflictPolicy
+		want        map[string]string
+		wantActions []EntryAction
+		wantErr     error
+	}{
+		// the table itself
+		{"POS overwrite", ConflictOverwrite, map[string]string{"data/a.txt": "a", "data/sub/b.txt": "b"},
+			[]EntryAction{ActionOverwritten, ActionCreated, ActionCreated, ActionCreated}, nil},
+		{"POS skip", ConflictSkip, map[string]string{"data": "file"},
+			[]EntryAction{ActionSkipped, ActionSkipped, ActionSkipped, ActionSkipped}, nil},
+		{"POS rename", ConflictRename, map[string]string{"data": "file", "data_1/a.txt": "a", "data_1/sub/b.txt": "b"},
+			[]EntryAction{ActionRenamed, ActionCreated, ActionCreated, ActionCreated}, nil},
+		{"NEG fail", ConflictFail, map[string]string{"data": "file"}, nil, os.ErrExist},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			writeTestTree(t, dest, map[string]string{"data": "file"})
+
+			var actions []EntryAction
+			opts := ExtractOptions{Overwrite: tt.policy, OnEntry: func(entry ArchiveEntry, target string, action EntryAction) {
+				actions = append(actions, action)
+			}}
+			err := ExtractTarGzWithOptions(bytes.NewReader(archive), dest, opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got := readTestTree(t, dest); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got tree %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("got actions %v, want %v", actions, tt.wantActions)
+			}
+		})
+	}
+}
+
+func TestExtractAllTarGzInDirectoryWithOptions(t *testing.T) {
+	archive := buildTarGz(t, []tarGzEntry{{name: "a.txt", typeflag: tar.TypeReg, content: []byte("a")}})
+	var plain bytes.Buffer
+	if err := Decompress(bytes.NewReader(archive), &plain); err != nil {
+		t.Fatal(err)
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name string
+		opts ExtractAllOptions
+		want []string // archives extracted and removed
+	}{
+		// the table itself
+		{"POS only tar.gz by default", ExtractAllOptions{}, []string{"a.tar.gz"}},
+		{"POS all tar archives", ExtractAllOptions{AllTarArchives: true}, []string{"a.tar.gz", "b.tar", "c.tgz"}},
+		{"POS recursive", ExtractAllOptions{Recursive: true}, []string{"a.tar.gz", "sub/d.tar.gz"}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := t.TempDir()
+			archives := map[string]string{"a.tar.gz": string(archive), "b.tar": plain.String(), "c.tgz": string(archive), "sub/d.tar.gz": string(archive)}
+			writeTestTree(t, src, archives)
+
+			dest := t.TempDir()
+			ExtractAllTarGzInDirectoryWithOptions(src, dest, tt.opts, nil)
+
+			for _, name := range tt.want {
+				delete(archives, name)
+			}
+			if got := readTestTree(t, src); !reflect.DeepEqual(got, archives) {
+				t.Errorf("got %d remaining archives, want %d", len(got), len(archives))
+			}
+			if got, err := os.ReadFile(filepath.Join(dest, "a.txt")); err != nil || string(got) != "a" {
+				t.Errorf("got extracted content %q : %v", got, err)
+			}
+		})
+	}
+}
+
+func TestCreateTarGz(t *testing.T) {
+	src := t.TempDir()
+	for path, content := range map[string]string{"a.csv": "a", "b.log": "b", "sub/c.csv": "c", "tmp/d.csv": "d"} {
+		path = filepath.Join(src, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The archive is written into the source directory and must not archive itself
+	archive := filepath.Join(src, "out.tar.gz")
+	opts := ArchiveOptions{Include: []string{"*.csv"}, Exclude: []string{"tmp"}, Reproducible: true, RemoveSources: true}
+	if err := CreateTarGzWithOptions(archive, src, "", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, removed := range map[string]bool{"a.csv": true, "sub/c.csv": true, "b.log": false, "tmp/d.csv": false} {
+		_, err := os.Stat(filepath.Join(src, filepath.FromSlash(path)))
+		if removed != os.IsNotExist(err) {
+			t.Errorf("%s: removed %v, want %v", path, os.IsNotExist(err), removed)
+		}
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	dest := t.TempDir()
+	if err := ExtractTarGz(f, dest); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{"a.csv": "a", "sub/c.csv": "c"} {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(path)))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, want %q : %v", path, got, want, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "b.log")); !os.IsNotExist(err) {
+		t.Errorf("b.log should not be archived")
+	}
+}
@@ -1,27 +1,46 @@
 package filehelper
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
-// Touch behaves like the 'touch' command in Unix - it creates a file if it doesn't exist,
-// and if it does exist, it deletes and recreates the file to update the timestamp.
+// TouchOptions controls TouchWithOptions.
+// The zero value creates missing files and sets the current time.
+type TouchOptions struct {
+	Time     time.Time // access and modification time to set, time.Now() if zero
+	NoCreate bool      // do not create missing files, like touch -c
+}
+
+// Touch behaves like the 'touch' command in Unix - it creates an empty file if it doesn't exist,
+// and if it does exist, it sets its access and modification time to the current time.
+// The content of an existing file is not changed.
 func Touch(path string) error {
-	// Check if file exists
-	_, err := os.Stat(path)
-	if err == nil {
-		// File exists, delete it
-		err = os.Remove(path)
-		if err != nil {
-			return err
-		}
-	} else if !os.IsNotExist(err) {
-		// Some other error occurred
+	return TouchWithOptions(path, TouchOptions{})
+}
+
+// TouchWithOptions works like Touch, but can set an explicit time and leave missing files alone
+func TouchWithOptions(path string, opts TouchOptions) error {
+	t := opts.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	err := os.Chtimes(path, t, t)
+	if err == nil || !os.IsNotExist(err) {
 		return err
 	}
+	if opts.NoCreate {
+		return nil
+	}
 
-	// Create file
-	file, err := os.Create(path)
+	// Create file, without truncating one created in the meantime
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
-	return file.Close()
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(path, t, t)
 }
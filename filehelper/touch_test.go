@@ -0,0 +1,88 @@
+package filehelper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTouchWithOptions(t *testing.T) {
+	explicit := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name       string
+		existing   bool
+		opts       TouchOptions
+		wantExists bool
+	}{
+		// the table itself
+		{"POS create missing", false, TouchOptions{}, true},
+		{"POS update existing", true, TouchOptions{}, true},
+		{"POS explicit time", true, TouchOptions{Time: explicit}, true},
+		{"POS explicit time on create", false, TouchOptions{Time: explicit}, true},
+		{"POS no create existing", true, TouchOptions{NoCreate: true}, true},
+		{"NEG no create missing", false, TouchOptions{NoCreate: true}, false},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.txt")
+			var before os.FileInfo
+			if tt.existing {
+				if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				old := time.Now().Add(-48 * time.Hour)
+				if err := os.Chtimes(path, old, old); err != nil {
+					t.Fatal(err)
+				}
+				var err error
+				if before, err = os.Stat(path); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			start := time.Now().Add(-time.Second)
+			if err := TouchWithOptions(path, tt.opts); err != nil {
+				t.Fatalf("TouchWithOptions() error = %v", err)
+			}
+
+			after, err := os.Stat(path)
+			if !tt.wantExists {
+				if !os.IsNotExist(err) {
+					t.Errorf("TouchWithOptions() created %s, stat error = %v", path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TouchWithOptions() did not create %s: %v", path, err)
+			}
+
+			if tt.opts.Time.IsZero() {
+				if after.ModTime().Before(start) {
+					t.Errorf("modification time = %v, want the current time", after.ModTime())
+				}
+			} else if !after.ModTime().Equal(tt.opts.Time) {
+				t.Errorf("modification time = %v, want %v", after.ModTime(), tt.opts.Time)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantContent := ""
+			if tt.existing {
+				wantContent = "content"
+				if !os.SameFile(before, after) {
+					t.Error("TouchWithOptions() replaced the existing file")
+				}
+			}
+			if string(content) != wantContent {
+				t.Errorf("content = %q, want %q", content, wantContent)
+			}
+		})
+	}
+}
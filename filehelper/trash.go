@@ -0,0 +1,263 @@
+package filehelper
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotInTrash is returned by Trash.Restore if no trashed file has the requested ID
+var ErrNotInTrash = errors.New("file not in trash")
+
+// Trash is a quarantine directory for deleted files. Instead of being removed, files are moved into
+// files/<YYYY-MM-DD>/<HHMMSS.nnnnnnnnn>_<name> below the trash directory, and a JSON record of their
+// origin is written to the same path below info/ with the extension ".json". Trashed files can be
+// restored with Restore and are removed for good by Purge.
+//
+// The deleting functions accept a *Trash in their options (DeleteOptions, ArchiveOptions,
+// RetentionPolicy, ExtractAllOptions); a nil Trash deletes permanently.
+type Trash struct {
+	Dir string // root directory of the trash
+}
+
+// TrashEntry describes a trashed file
+type TrashEntry struct {
+	ID           string      `json:"id"`            // path of the file relative to the files directory of the trash
+	OriginalPath string      `json:"original_path"` // absolute path the file was deleted from
+	DeletedAt    time.Time   `json:"deleted_at"`
+	Size         int64       `json:"size"`
+	Mode         fs.FileMode `json:"mode"`
+}
+
+// NewTrash returns the trash in dir, creating its directories if necessary
+func NewTrash(dir string) (*Trash, error) {
+	t := &Trash{Dir: dir}
+	for _, sub := range []string{t.filesDir(), t.infoDir()} {
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Put moves the file at path into the trash and records its origin.
+// Files on another file system are copied into the trash and then removed.
+//
+// The record is written before the file is moved and removed again if the move fails, so a trashed
+// file always has a record and can be listed, restored and purged.
+func (t *Trash) Put(path string) (TrashEntry, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	info, err := os.Lstat(abs)
+	if err != nil {
+		return TrashEntry{}, err
+	}
+	if info.IsDir() {
+		return TrashEntry{}, errors.New("cannot trash directory " + path)
+	}
+
+	now := time.Now()
+	dir := filepath.Join(t.filesDir(), now.Format("2006-01-02"))
+	name := now.Format("150405.000000000") + "_" + info.Name()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return TrashEntry{}, err
+	}
+
+	for {
+		target := filepath.Join(dir, name)
+		if _, err := os.Lstat(target); err == nil {
+			if target, err = uniquePath(target); err != nil {
+				return TrashEntry{}, err
+			}
+		}
+
+		id, err := filepath.Rel(t.filesDir(), target)
+		if err != nil {
+			return TrashEntry{}, err
+		}
+		entry := TrashEntry{
+			ID:           filepath.ToSlash(id),
+			OriginalPath: abs,
+			DeletedAt:    now,
+			Size:         info.Size(),
+			Mode:         info.Mode(),
+		}
+		if err := t.writeEntry(entry); err != nil {
+			return TrashEntry{}, err
+		}
+
+		_, _, err = MoveFileWithOptions(abs, dir, filepath.Base(target), CopyOptions{Conflict: ConflictFail})
+		if err == nil {
+			return entry, nil
+		}
+		_ = os.Remove(t.infoPath(entry.ID))
+		if !errors.Is(err, os.ErrExist) {
+			return TrashEntry{}, err
+		}
+		// Another file took the name in the meantime, try the next one
+	}
+}
+
+// writeEntry writes the record of a trashed file
+func (t *Trash) writeEntry(entry TrashEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	infoPath := t.infoPath(entry.ID)
+	if err := os.MkdirAll(filepath.Dir(infoPath), 0755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(infoPath, data, 0644)
+}
+
+// List returns all trashed files, oldest first
+func (t *Trash) List() ([]TrashEntry, error) {
+	var entries []TrashEntry
+	err := filepath.WalkDir(t.infoDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		entry, err := readTrashEntry(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.Before(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+// Restore moves the trashed file with the given ID back to its original path, creating missing
+// parent directories. If a file exists at the original path, the conflict policy decides; with
+// ConflictSkip the file stays in the trash. It returns the path the file was restored to.
+func (t *Trash) Restore(id string, conflict ConflictPolicy) (string, EntryAction, error) {
+	if !filepath.IsLocal(filepath.FromSlash(id)) {
+		return "", ActionSkipped, ErrNotInTrash
+	}
+	entry, err := readTrashEntry(t.infoPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ActionSkipped, ErrNotInTrash
+	}
+	if err != nil {
+		return "", ActionSkipped, err
+	}
+
+	dir, name := filepath.Split(entry.OriginalPath)
+	path, action, err := MoveFileWithOptions(t.filePath(entry.ID), dir, name, CopyOptions{Conflict: conflict})
+	if err != nil || action == ActionSkipped {
+		return path, action, err
+	}
+
+	return path, action, t.forget(entry.ID)
+}
+
+// Purge permanently removes the trashed files deleted more than maxAge ago and returns them.
+// All entries are tried, errors are joined.
+func (t *Trash) Purge(maxAge time.Duration) ([]TrashEntry, error) {
+	entries, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var purged []TrashEntry
+	var errs []error
+	for _, entry := range entries {
+		if !entry.DeletedAt.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(t.filePath(entry.ID)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		if err := t.forget(entry.ID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		purged = append(purged, entry)
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+// forget removes the record of a trashed file and the day directories left empty
+func (t *Trash) forget(id string) error {
+	if err := os.Remove(t.infoPath(id)); err != nil {
+		return err
+	}
+	// Only succeeds once the day directory is empty
+	_ = os.Remove(filepath.Dir(t.infoPath(id)))
+	_ = os.Remove(filepath.Dir(t.filePath(id)))
+	return nil
+}
+
+func (t *Trash) filesDir() string {
+	return filepath.Join(t.Dir, "files")
+}
+
+func (t *Trash) infoDir() string {
+	return filepath.Join(t.Dir, "info")
+}
+
+func (t *Trash) filePath(id string) string {
+	return filepath.Join(t.filesDir(), filepath.FromSlash(id))
+}
+
+func (t *Trash) infoPath(id string) string {
+	return filepath.Join(t.infoDir(), filepath.FromSlash(id)+".json")
+}
+
+// readTrashEntry reads the record of a trashed file
+func readTrashEntry(path string) (TrashEntry, error) {
+	var entry TrashEntry
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(data, &entry)
+	return entry, err
+}
+
+// removeOrTrash removes the file at path, or moves it into the trash if trash is not nil
+func removeOrTrash(path string, trash *Trash) error {
+	if trash == nil {
+		return os.Remove(path)
+	}
+	_, err := trash.Put(path)
+	return err
+}
+
+// inTrash reports whether path is the root directory of trash or below it, so walks can skip it
+func inTrash(path string, trash *Trash) bool {
+	if trash == nil {
+		return false
+	}
+	a, err1 := filepath.Abs(path)
+	b, err2 := filepath.Abs(trash.Dir)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(b, a)
+	return err == nil && filepath.IsLocal(rel)
+}
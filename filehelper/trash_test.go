@@ -0,0 +1,231 @@
+package filehelper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// trashedPaths returns the original paths of the files in the trash, sorted
+func trashedPaths(t *testing.T, trash *Trash) []string {
+	t.Helper()
+	entries, err := trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, entry := range entries {
+		paths = append(paths, entry.OriginalPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestTrashPutRestore(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name     string
+		existing bool // a new file was created at the original path after trashing
+		conflict ConflictPolicy
+		action   EntryAction
+		restored string // file name of the restored file, empty if it stays in the trash
+		wantErr  error
+	}{
+		// the table itself
+		{"POS restore", false, ConflictFail, ActionCreated, "report.csv", nil},
+		{"POS overwrite", true, ConflictOverwrite, ActionOverwritten, "report.csv", nil},
+		{"POS rename", true, ConflictRename, ActionRenamed, "report_1.csv", nil},
+		{"POS skip", true, ConflictSkip, ActionSkipped, "", nil},
+		{"NEG fail", true, ConflictFail, ActionSkipped, "", os.ErrExist},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			trash, err := NewTrash(filepath.Join(t.TempDir(), "trash"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "sub", "report.csv")
+			writeTestTree(t, dir, map[string]string{"sub/report.csv": "old"})
+
+			entry, err := trash.Put(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("got %v, want the file gone", err)
+			}
+			if entry.OriginalPath != path || entry.Size != 3 {
+				t.Errorf("got %+v", entry)
+			}
+			if got := trashedPaths(t, trash); !reflect.DeepEqual(got, []string{path}) {
+				t.Errorf("got trash %v", got)
+			}
+
+			// The original directory may be gone meanwhile
+			if err := os.RemoveAll(filepath.Join(dir, "sub")); err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing {
+				writeTestTree(t, dir, map[string]string{"sub/report.csv": "new"})
+			}
+
+			got, action, err := trash.Restore(entry.ID, tt.conflict)
+			if !errors.Is(err, tt.wantErr) || action != tt.action {
+				t.Fatalf("got %v : %v, want %v : %v", action, err, tt.action, tt.wantErr)
+			}
+			if tt.restored == "" {
+				if paths := trashedPaths(t, trash); len(paths) != 1 {
+					t.Errorf("got trash %v, want the file still in it", paths)
+				}
+				return
+			}
+			if want := filepath.Join(dir, "sub", tt.restored); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+			if content, err := os.ReadFile(got); err != nil || string(content) != "old" {
+				t.Errorf("got %q : %v", content, err)
+			}
+			// The record is forgotten and the day directories are removed
+			if paths := trashedPaths(t, trash); len(paths) != 0 {
+				t.Errorf("got trash %v, want it empty", paths)
+			}
+			for _, sub := range []string{"files", "info"} {
+				if entries, err := os.ReadDir(filepath.Join(trash.Dir, sub)); err != nil || len(entries) != 0 {
+					t.Errorf("got %d entries in %s : %v", len(entries), sub, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTrashPutRecordFailure(t *testing.T) {
+	trash, err := NewTrash(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A file in place of the info directory makes writing the record fail
+	if err := os.Remove(trash.infoDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(trash.infoDir(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trash.Put(path); err == nil {
+		t.Fatal("got no error")
+	}
+
+	// The file is not moved without its record
+	if got, err := os.ReadFile(path); err != nil || string(got) != "data" {
+		t.Errorf("got %q : %v, want the file in place", got, err)
+	}
+	trashed, err := ListFiles(trash.filesDir(), ListOptions{MaxDepth: UnlimitedDepth})
+	if err != nil || len(trashed) != 0 {
+		t.Errorf("got %d trashed files : %v, want none", len(trashed), err)
+	}
+}
+
+func TestTrashRestoreInvalid(t *testing.T) {
+	trash, err := NewTrash(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"2024-06-30/missing.csv", "../info/x", "/etc/passwd"} {
+		if _, _, err := trash.Restore(id, ConflictFail); !errors.Is(err, ErrNotInTrash) {
+			t.Errorf("%s: got %v, want %v", id, err, ErrNotInTrash)
+		}
+	}
+}
+
+func TestTrashPurge(t *testing.T) {
+	dir := t.TempDir()
+	trash, err := NewTrash(filepath.Join(dir, ".trash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestTree(t, dir, map[string]string{"a.csv": "a", "b.csv": "b"})
+	for _, name := range []string{"a.csv", "b.csv"} {
+		if _, err := trash.Put(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if purged, err := trash.Purge(time.Hour); err != nil || len(purged) != 0 {
+		t.Errorf("got %d purged : %v, want none", len(purged), err)
+	}
+	if purged, err := trash.Purge(0); err != nil || len(purged) != 2 {
+		t.Errorf("got %d purged : %v, want 2", len(purged), err)
+	}
+	if got := readTestTree(t, trash.Dir); len(got) != 0 {
+		t.Errorf("got %v left in the trash", got)
+	}
+}
+
+func TestTrashOptions(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name    string
+		delete  func(dir string, trash *Trash) error
+		trashed []string // relative paths of the files expected in the trash
+	}{
+		// the table itself
+		{"POS delete files", func(dir string, trash *Trash) error {
+			return DeleteFilesInDirWithOptions(dir, []string{"csv"}, DeleteOptions{Trash: trash})
+		}, []string{"a.csv"}},
+		{"POS archive", func(dir string, trash *Trash) error {
+			return CreateTarGzWithOptions(filepath.Join(t.TempDir(), "out.tar.gz"), dir, "", ArchiveOptions{
+				RemoveSources: true, Trash: trash,
+			})
+		}, []string{"a.csv", "b.log", "sub/c.csv"}},
+		{"POS retention", func(dir string, trash *Trash) error {
+			report, err := ApplyRetention(dir, RetentionPolicy{Patterns: []string{"*.csv"}, KeepLast: 1, Recursive: true,
+				Trash: trash, PruneEmptyDirs: true})
+			if err == nil {
+				err = report.Err()
+			}
+			return err
+		}, []string{"a.csv"}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			trash, err := NewTrash(filepath.Join(dir, ".trash"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTestTree(t, dir, map[string]string{"a.csv": "a", "b.log": "b", "sub/c.csv": "c"})
+			old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			if err := os.Chtimes(filepath.Join(dir, "a.csv"), old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.delete(dir, trash); err != nil {
+				t.Fatal(err)
+			}
+
+			var want []string
+			for _, rel := range tt.trashed {
+				want = append(want, filepath.Join(dir, filepath.FromSlash(rel)))
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel))); !os.IsNotExist(err) {
+					t.Errorf("%s: got %v, want it gone", rel, err)
+				}
+			}
+			if got := trashedPaths(t, trash); !reflect.DeepEqual(got, want) {
+				t.Errorf("got trash %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package filehelper
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// umask is the file mode creation mask of the process, read once at start up. Changing the umask
+// means changing it for the whole process, so it is not read again while other goroutines may be
+// creating files.
+var umask = readUmask()
+
+// readUmask returns the current umask of the process
+func readUmask() os.FileMode {
+	mask := unix.Umask(0)
+	unix.Umask(mask)
+	return os.FileMode(mask)
+}
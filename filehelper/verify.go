@@ -0,0 +1,133 @@
+package filehelper
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// VerifyReport describes an archive which was read completely by VerifyGzip or VerifyTarGz
+type VerifyReport struct {
+	CompressedSize   int64     // size of the archive file in bytes
+	UncompressedSize int64     // size of the decompressed stream in bytes
+	Name             string    // original file name from the gzip header (first member)
+	ModTime          time.Time // modification time from the gzip header (first member)
+	Entries          int       // tar only: number of entries
+	Files            int       // tar only: number of regular files
+	Dirs             int       // tar only: number of directories
+	Links            int       // tar only: number of symbolic and hard links
+	ContentSize      int64     // tar only: total size of the regular files
+}
+
+// VerifyGzip reads the gzip file at path completely without writing anything to disk.
+// The CRC-32 and the size stored in the trailer of every gzip member are checked, so a
+// truncated or corrupted file results in an error (e.g. gzip.ErrChecksum or io.ErrUnexpectedEOF).
+// On success, the report holds the sizes and the header metadata.
+func VerifyGzip(path string) (VerifyReport, error) {
+	var report VerifyReport
+
+	f, err := os.Open(path)
+	if err != nil {
+		return report, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	counter := &countingReader{r: f}
+	gr, err := gzip.NewReader(bufio.NewReader(counter))
+	if err != nil {
+		return report, fmt.Errorf("verification of %s failed: %w", path, err)
+	}
+	report.Name, report.ModTime = gr.Name, gr.ModTime
+
+	report.UncompressedSize, err = io.Copy(io.Discard, gr)
+	if err != nil {
+		return report, fmt.Errorf("verification of %s failed: %w", path, err)
+	}
+	report.CompressedSize = counter.n
+
+	return report, nil
+}
+
+// VerifyTarGz reads the tar archive at path completely without writing anything to disk.
+// The compression is detected like in ExtractTar, so uncompressed archives are read as well;
+// for gzip the CRCs are checked as in VerifyGzip.
+// Every tar header and the content of every entry is read, so a damaged archive results in an error.
+// On success, the report holds the sizes and the number of entries by type.
+func VerifyTarGz(path string) (VerifyReport, error) {
+	var report VerifyReport
+
+	f, err := os.Open(path)
+	if err != nil {
+		return report, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	counter := &countingReader{r: f}
+	br := bufio.NewReader(counter)
+	codec, err := DetectCodec(br)
+	if err != nil && err != ErrUnknownFormat {
+		return report, fmt.Errorf("verification of %s failed: %w", path, err)
+	}
+
+	var reader io.ReadCloser
+	if err == ErrUnknownFormat {
+		// Not compressed, the file is read as plain tar stream
+		reader = io.NopCloser(br)
+	} else if codec == Gzip {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return report, fmt.Errorf("verification of %s failed: %w", path, err)
+		}
+		report.Name, report.ModTime = gr.Name, gr.ModTime
+		reader = gr
+	} else if reader, err = codec.NewReader(br); err != nil {
+		return report, fmt.Errorf("verification of %s failed: %w", path, err)
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(reader)
+
+	uncompressed := &countingReader{r: reader}
+	tr := tar.NewReader(uncompressed)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("verification of %s failed: %w", path, err)
+		}
+
+		report.Entries++
+		switch header.Typeflag {
+		case tar.TypeReg:
+			report.Files++
+			report.ContentSize += header.Size
+		case tar.TypeDir:
+			report.Dirs++
+		case tar.TypeSymlink, tar.TypeLink:
+			report.Links++
+		}
+
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return report, fmt.Errorf("verification of %s failed: %w", path, err)
+		}
+	}
+
+	// Read the padding after the end-of-archive marker, so the checksums of the compression are checked
+	if _, err := io.Copy(io.Discard, uncompressed); err != nil {
+		return report, fmt.Errorf("verification of %s failed: %w", path, err)
+	}
+	report.UncompressedSize = uncompressed.n
+	report.CompressedSize = counter.n
+
+	return report, nil
+}
@@ -0,0 +1,127 @@
+package filehelper
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// corruptTrailer flips a bit in the CRC-32 stored in the gzip trailer
+func corruptTrailer(data []byte) []byte {
+	data = bytes.Clone(data)
+	data[len(data)-8] ^= 0x01
+	return data
+}
+
+func TestVerifyGzip(t *testing.T) {
+	var valid bytes.Buffer
+	gw := gzip.NewWriter(&valid)
+	gw.Name = "data.csv"
+	gw.ModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := gw.Write(bytes.Repeat([]byte("a;b;c\n"), 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name string
+		data []byte
+		want error
+	}{
+		// the table itself
+		{"POS valid", valid.Bytes(), nil},
+		{"NEG corrupt trailer", corruptTrailer(valid.Bytes()), gzip.ErrChecksum},
+		{"NEG truncated", valid.Bytes()[:valid.Len()-4], io.ErrUnexpectedEOF},
+		{"NEG not gzip", []byte("plain text"), gzip.ErrHeader},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.csv.gz")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := VerifyGzip(path)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Name != "data.csv" || !report.ModTime.Equal(gw.ModTime) {
+				t.Errorf("got header %q %s", report.Name, report.ModTime)
+			}
+			if report.UncompressedSize != 600 || report.CompressedSize != int64(len(tt.data)) {
+				t.Errorf("got sizes %d/%d", report.UncompressedSize, report.CompressedSize)
+			}
+		})
+	}
+}
+
+func TestVerifyTarGz(t *testing.T) {
+	valid := buildTarGz(t, []tarGzEntry{
+		{name: "dir/", typeflag: tar.TypeDir},
+		{name: "dir/a.txt", typeflag: tar.TypeReg, content: []byte("aaa")},
+		{name: "dir/b.txt", typeflag: tar.TypeReg, content: []byte("bb")},
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "dir/a.txt"},
+	})
+	var plain bytes.Buffer
+	if err := Decompress(bytes.NewReader(valid), &plain); err != nil {
+		t.Fatal(err)
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name string
+		data []byte
+		want error
+	}{
+		// the table itself
+		{"POS valid", valid, nil},
+		{"NEG corrupt trailer", corruptTrailer(valid), gzip.ErrChecksum},
+		{"NEG truncated", valid[:len(valid)/2], io.ErrUnexpectedEOF},
+		{"POS uncompressed", plain.Bytes(), nil},
+		{"NEG not a tar archive", bytes.Repeat([]byte("plain text"), 200), tar.ErrHeader},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.tar.gz")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := VerifyTarGz(path)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Entries != 4 || report.Files != 2 || report.Dirs != 1 || report.Links != 1 || report.ContentSize != 5 {
+				t.Errorf("got %+v", report)
+			}
+			if report.CompressedSize != int64(len(tt.data)) {
+				t.Errorf("got compressed size %d, want %d", report.CompressedSize, len(tt.data))
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package filehelper
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/smithyat/go-helpers/logger"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errNoInotify is returned by initInotify on platforms without inotify
+var errNoInotify = errors.New("inotify not supported on this platform")
+
+// WatchReason tells why the watcher considers a file complete
+type WatchReason int
+
+const (
+	WatchClosed  WatchReason = iota // the file was closed after writing (inotify only)
+	WatchMovedIn                    // the file was renamed or moved into the directory (inotify only)
+	WatchStable                     // size and modification time did not change for the quiet period
+)
+
+// String returns the name of the reason
+func (r WatchReason) String() string {
+	switch r {
+	case WatchClosed:
+		return "closed"
+	case WatchMovedIn:
+		return "moved in"
+	case WatchStable:
+		return "stable"
+	default:
+		return "unknown"
+	}
+}
+
+// WatchEvent is sent by Watch for every complete file
+type WatchEvent struct {
+	Path    string
+	Name    string
+	Size    int64
+	ModTime time.Time
+	Reason  WatchReason
+}
+
+// WatchOptions controls Watch. The zero value watches all files with inotify (on Linux, polling
+// elsewhere), ignores the files present at the start and uses a quiet period of 5 seconds and a
+// poll interval of 1 second.
+type WatchOptions struct {
+	Patterns      []string      // glob patterns of the file names to report, empty reports all files
+	QuietPeriod   time.Duration // time the size and modification time of a file must stay unchanged to be stable
+	PollInterval  time.Duration // interval of the directory scans and stability checks
+	ForcePolling  bool          // do not use inotify, e.g. on network file systems where it sees no remote changes
+	ExistingFiles bool          // report the files present at the start, once they are stable
+}
+
+// Watch watches the directory dir (not its subdirectories) and sends an event for every regular
+// file matching the patterns once it is complete. With inotify, a file is complete when it is closed
+// after writing or moved into the directory. Without inotify (ForcePolling, on other platforms than
+// Linux, or if inotify cannot be set up), the directory is scanned every PollInterval and a file is complete once its size and
+// modification time did not change for QuietPeriod. A file is reported again when it changes later.
+// Temporary files of an AtomicWriter (".name.tmp-*") are ignored.
+//
+// The watcher stops and closes the channel when ctx is done. Errors while watching are logged to
+// logPtr, which can be nil.
+func Watch(ctx context.Context, dir string, opts WatchOptions, logPtr *logrus.Entry) (<-chan WatchEvent, error) {
+	if opts.QuietPeriod <= 0 {
+		opts.QuietPeriod = 5 * time.Second
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if logPtr == nil {
+		logPtr = logrus.NewEntry(logrus.New())
+		logPtr.Logger.SetOutput(io.Discard)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(dir + " is not a directory")
+	}
+
+	w := &watcher{
+		dir:     dir,
+		opts:    opts,
+		log:     logPtr,
+		events:  make(chan WatchEvent),
+		pending: make(map[string]pendingFile),
+		emitted: make(map[string]pendingFile),
+	}
+
+	fd := -1
+	if !opts.ForcePolling {
+		fd, err = w.initInotify()
+		if err != nil && err != errNoInotify {
+			logPtr.Warnf("inotify not available for %s, polling instead: %v [%s]", dir, err, logger.Trace())
+		}
+	}
+
+	// Files present at the start are reported once stable, or only after they change
+	w.scan(time.Now(), !opts.ExistingFiles)
+
+	if fd >= 0 {
+		go w.runInotify(ctx, fd)
+	} else {
+		go w.poll(ctx)
+	}
+	return w.events, nil
+}
+
+// pendingFile is the state of a file the watcher waits for
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	since   time.Time // when size and modification time were seen first
+	stable  bool      // unchanged for the quiet period, to be reported
+}
+
+type watcher struct {
+	dir     string
+	opts    WatchOptions
+	log     *logrus.Entry
+	events  chan WatchEvent
+	pending map[string]pendingFile // files waiting to become stable
+	emitted map[string]pendingFile // state of the files when they were reported last
+}
+
+// poll is the loop of the watcher without inotify
+func (w *watcher) poll(ctx context.Context) {
+	defer close(w.events)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.opts.PollInterval):
+		}
+		w.scan(time.Now(), false)
+		if !w.flush(ctx) {
+			return
+		}
+	}
+}
+
+// complete sends the event of a file inotify reported complete
+func (w *watcher) complete(ctx context.Context, name string, reason WatchReason) {
+	if !w.matches(name) {
+		return
+	}
+	info, err := os.Lstat(filepath.Join(w.dir, name))
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	delete(w.pending, name)
+	w.send(ctx, name, info, reason)
+}
+
+// scan lists the directory and updates the pending files. With ignore, the current state of all
+// files is taken as already reported.
+func (w *watcher) scan(now time.Time, ignore bool) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		w.log.Errorf("failed to read directory %s: %v [%s]", w.dir, err, logger.Trace())
+		return
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !w.matches(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		seen[name] = true
+
+		state := pendingFile{size: info.Size(), modTime: info.ModTime(), since: now}
+		if ignore {
+			w.emitted[name] = state
+			continue
+		}
+		if last, ok := w.emitted[name]; ok && last.size == state.size && last.modTime.Equal(state.modTime) {
+			continue
+		}
+		if last, ok := w.pending[name]; ok && last.size == state.size && last.modTime.Equal(state.modTime) {
+			continue
+		}
+		w.pending[name] = state
+	}
+
+	for name := range w.pending {
+		if !seen[name] {
+			delete(w.pending, name)
+		}
+	}
+	for name := range w.emitted {
+		if !seen[name] {
+			delete(w.emitted, name)
+		}
+	}
+
+	w.checkPending(now)
+}
+
+// checkPending re-checks the pending files and queues the ones stable for the quiet period
+func (w *watcher) checkPending(now time.Time) {
+	for name, state := range w.pending {
+		info, err := os.Lstat(filepath.Join(w.dir, name))
+		if err != nil || !info.Mode().IsRegular() {
+			delete(w.pending, name)
+			continue
+		}
+		if info.Size() != state.size || !info.ModTime().Equal(state.modTime) {
+			w.pending[name] = pendingFile{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+		if now.Sub(state.since) >= w.opts.QuietPeriod {
+			state.stable = true
+			w.pending[name] = state
+		}
+	}
+}
+
+// flush sends the events of the pending files found stable. It returns false if ctx is done.
+func (w *watcher) flush(ctx context.Context) bool {
+	for name, state := range w.pending {
+		if !state.stable {
+			continue
+		}
+		delete(w.pending, name)
+		info, err := os.Lstat(filepath.Join(w.dir, name))
+		if err != nil {
+			continue
+		}
+		if !w.send(ctx, name, info, WatchStable) {
+			return false
+		}
+	}
+	return true
+}
+
+// send delivers the event of a file, unless it was already reported in the same state.
+// It returns false if ctx is done.
+func (w *watcher) send(ctx context.Context, name string, info os.FileInfo, reason WatchReason) bool {
+	state := pendingFile{size: info.Size(), modTime: info.ModTime()}
+	if last, ok := w.emitted[name]; ok && last.size == state.size && last.modTime.Equal(state.modTime) {
+		return true
+	}
+
+	event := WatchEvent{
+		Path:    filepath.Join(w.dir, name),
+		Name:    name,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Reason:  reason,
+	}
+	select {
+	case w.events <- event:
+		w.emitted[name] = state
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// matches reports whether the name matches the patterns of the watcher. Temporary files of an
+// AtomicWriter never match, the file is reported under its final name.
+func (w *watcher) matches(name string) bool {
+	if isAtomicTemp(name) {
+		return false
+	}
+	return len(w.opts.Patterns) == 0 || matchAnyGlob(w.opts.Patterns, name, name)
+}
@@ -0,0 +1,99 @@
+package filehelper
+
+import (
+	"context"
+	"errors"
+	"github.com/smithyat/go-helpers/logger"
+	"golang.org/x/sys/unix"
+	"time"
+	"unsafe"
+)
+
+// initInotify sets up a non-blocking inotify instance watching the directory
+func (w *watcher) initInotify() (int, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return -1, err
+	}
+	mask := uint32(unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE | unix.IN_ONLYDIR)
+	if _, err := unix.InotifyAddWatch(fd, w.dir, mask); err != nil {
+		_ = unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// runInotify is the loop of the watcher with the inotify instance fd
+func (w *watcher) runInotify(ctx context.Context, fd int) {
+	defer close(w.events)
+	defer func(fd int) {
+		_ = unix.Close(fd)
+	}(fd)
+
+	buf := make([]byte, 64*1024)
+	lastScan := time.Now()
+	for ctx.Err() == nil {
+		// Wake up regularly to check the context and the pending files
+		timeout := 200 * time.Millisecond
+		if w.opts.PollInterval < timeout {
+			timeout = w.opts.PollInterval
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(timeout.Milliseconds()))
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			w.log.Errorf("failed to wait for inotify events on %s: %v [%s]", w.dir, err, logger.Trace())
+			return
+		}
+		if n > 0 && !w.readInotify(ctx, fd, buf) {
+			return
+		}
+		if len(w.pending) > 0 && time.Since(lastScan) >= w.opts.PollInterval {
+			lastScan = time.Now()
+			w.checkPending(time.Now())
+		}
+		if !w.flush(ctx) {
+			return
+		}
+	}
+}
+
+// readInotify reads and handles the available inotify events. It returns false if the watcher must stop.
+func (w *watcher) readInotify(ctx context.Context, fd int, buf []byte) bool {
+	n, err := unix.Read(fd, buf)
+	if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+		return true
+	}
+	if err != nil {
+		w.log.Errorf("failed to read inotify events on %s: %v [%s]", w.dir, err, logger.Trace())
+		return false
+	}
+
+	for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+		offset += unix.SizeofInotifyEvent + int(event.Len)
+
+		name := string(nameBytes)
+		for len(name) > 0 && name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+
+		switch {
+		case event.Mask&unix.IN_Q_OVERFLOW != 0:
+			// Events were lost, fall back to the stability check for everything
+			w.log.Warnf("inotify queue overflow on %s, rescanning [%s]", w.dir, logger.Trace())
+			w.scan(time.Now(), false)
+		case event.Mask&(unix.IN_IGNORED|unix.IN_DELETE_SELF) != 0:
+			w.log.Errorf("watched directory %s was removed [%s]", w.dir, logger.Trace())
+			return false
+		case event.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+			delete(w.pending, name)
+			delete(w.emitted, name)
+		case event.Mask&unix.IN_CLOSE_WRITE != 0:
+			w.complete(ctx, name, WatchClosed)
+		case event.Mask&unix.IN_MOVED_TO != 0:
+			w.complete(ctx, name, WatchMovedIn)
+		}
+	}
+	return ctx.Err() == nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package filehelper
+
+import "context"
+
+// initInotify always fails with errNoInotify, the watcher polls instead
+func (w *watcher) initInotify() (int, error) {
+	return -1, errNoInotify
+}
+
+// runInotify is never called without inotify, it polls like Watch with ForcePolling
+func (w *watcher) runInotify(ctx context.Context, fd int) {
+	w.poll(ctx)
+}
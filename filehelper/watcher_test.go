@@ -0,0 +1,125 @@
+package filehelper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// collectEvents reads the events of the watcher until no event arrived for idle
+func collectEvents(events <-chan WatchEvent, idle time.Duration) map[string]WatchReason {
+	got := make(map[string]WatchReason)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return got
+			}
+			got[event.Name] = event.Reason
+		case <-time.After(idle):
+			return got
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	closed, movedIn := WatchStable, WatchStable
+	if runtime.GOOS == "linux" {
+		closed, movedIn = WatchClosed, WatchMovedIn
+	}
+
+	// Defining the columns of the table
+	var tests = []struct {
+		name string
+		opts WatchOptions
+		want map[string]WatchReason // reported file names and the reason
+	}{
+		// the table itself
+		{"POS polling", WatchOptions{ForcePolling: true, Patterns: []string{"*.csv"}},
+			map[string]WatchReason{"written.csv": WatchStable, "atomic.csv": WatchStable, "moved.csv": WatchStable}},
+		{"POS polling existing files", WatchOptions{ForcePolling: true, Patterns: []string{"*.csv"}, ExistingFiles: true},
+			map[string]WatchReason{"existing.csv": WatchStable, "written.csv": WatchStable, "atomic.csv": WatchStable, "moved.csv": WatchStable}},
+		{"POS inotify", WatchOptions{Patterns: []string{"*.csv"}},
+			map[string]WatchReason{"written.csv": closed, "atomic.csv": movedIn, "moved.csv": movedIn}},
+		{"POS inotify all files", WatchOptions{},
+			map[string]WatchReason{"written.csv": closed, "ignored.txt": closed, "atomic.csv": movedIn, "moved.csv": movedIn}},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestTree(t, dir, map[string]string{"existing.csv": "e"})
+			other := filepath.Join(t.TempDir(), "moved.csv")
+			writeTestTree(t, filepath.Dir(other), map[string]string{"moved.csv": "m"})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			opts := tt.opts
+			opts.QuietPeriod, opts.PollInterval = 100*time.Millisecond, 20*time.Millisecond
+			events, err := Watch(ctx, dir, opts, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writeTestTree(t, dir, map[string]string{"written.csv": "w", "ignored.txt": "i"})
+			if err := WriteFileAtomic(filepath.Join(dir, "atomic.csv"), []byte("a"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(other, filepath.Join(dir, "moved.csv")); err != nil {
+				t.Fatal(err)
+			}
+
+			got := collectEvents(events, time.Second)
+			if len(got) != len(tt.want) {
+				var names []string
+				for name := range got {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				t.Fatalf("got %v, want %d files", names, len(tt.want))
+			}
+			for name, reason := range tt.want {
+				if got[name] != reason {
+					t.Errorf("%s: got %v, want %v", name, got[name], reason)
+				}
+			}
+
+			// The channel is closed once the context is done
+			cancel()
+			if _, ok := <-events; ok {
+				t.Error("got an event after cancel")
+			}
+		})
+	}
+}
+
+func TestIsAtomicTemp(t *testing.T) {
+	// Defining the columns of the table
+	var tests = []struct {
+		name string
+		file string
+		want bool
+	}{
+		// the table itself
+		{"POS temporary file", ".report.csv.tmp-123456", true},
+		{"NEG final name", "report.csv", false},
+		{"NEG hidden file", ".report.csv", false},
+		{"NEG no random part", ".report.csv.tmp-", false},
+		{"NEG not hidden", "report.csv.tmp-123", false},
+		{"NEG other suffix", ".report.tmp-old", false},
+	}
+
+	// The execution loop
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAtomicTemp(tt.file); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
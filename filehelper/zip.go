@@ -0,0 +1,364 @@
+package filehelper
+
+import (
+	"archive/zip"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/smithyat/go-helpers/logger"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxZipLinkSize is the maximum length of a symlink target stored as ZIP entry content
+const maxZipLinkSize = 4096
+
+// ExtractAllZipInDirectory reads the specified 'srcDir' and for every .zip file found,
+// it extracts the file to the 'destDir' and removes the .zip file after a successful extraction.
+// It logs any error encountered during the file operations via the provided logrus Logger,
+// if it is not nil, and moves on to the next file. It does not look into any subdirectories of 'srcDir'.
+//
+// This is the ZIP counterpart of ExtractAllTarGzInDirectory.
+func ExtractAllZipInDirectory(srcDir, destDir string, logPtr *logrus.Entry) {
+	files, err := os.ReadDir(srcDir)
+	if err != nil {
+		if logPtr != nil {
+			logPtr.Errorf("failed to read directory %s: %v [%s]", srcDir, err, logger.Trace())
+		}
+		return
+	}
+
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(strings.ToLower(file.Name()), ".zip") {
+			extractZipAndRemove(filepath.Join(srcDir, file.Name()), destDir, logPtr)
+		}
+	}
+}
+
+// ExtractAllZipInDirectoryRecursive walks through the provided source directory recursively,
+// extracts all .zip files found into the destination directory and removes each .zip file after
+// a successful extraction. Errors are logged using the provided logrus entry, if it is not nil.
+//
+// This is the ZIP counterpart of ExtractAllTarGzInDirectoryRecursive.
+func ExtractAllZipInDirectoryRecursive(srcDir, destDir string, logPtr *logrus.Entry) {
+	_ = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if logPtr != nil {
+				logPtr.Errorf("failed to walk path %s: %v [%s]", path, err, logger.Trace())
+			}
+			return nil
+		}
+
+		if !info.IsDir() && strings.HasSuffix(strings.ToLower(info.Name()), ".zip") {
+			extractZipAndRemove(path, destDir, logPtr)
+		}
+		return nil
+	})
+}
+
+// extractZipAndRemove extracts the zip file at path into destDir and removes it on success.
+// All errors are logged to logPtr, if it is not nil.
+func extractZipAndRemove(path, destDir string, logPtr *logrus.Entry) {
+	if logPtr != nil {
+		logPtr.Infof("extracting file %s", path)
+	}
+
+	if err := ExtractZipFile(path, destDir); err != nil {
+		if logPtr != nil {
+			logPtr.Errorf("failed to extract file %s: %v [%s]", path, err, logger.Trace())
+		}
+		return
+	}
+
+	if logPtr != nil {
+		logPtr.Infof("extracted file %s", path)
+	}
+
+	if err := os.Remove(path); err != nil && logPtr != nil {
+		logPtr.Errorf("failed to remove file %s: %v [%s]", path, err, logger.Trace())
+	}
+}
+
+// ExtractZipFile opens the ZIP archive at zipPath and extracts it into dest using ExtractZip.
+func ExtractZipFile(zipPath, dest string) error {
+	return ExtractZipFileWithOptions(zipPath, dest, ExtractOptions{})
+}
+
+// ExtractZipFileWithOptions opens the ZIP archive at zipPath and extracts it into dest using ExtractZipWithOptions.
+func ExtractZipFileWithOptions(zipPath, dest string, opts ExtractOptions) error {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return ExtractZipWithOptions(f, info.Size(), dest, opts)
+}
+
+// ExtractZip extracts the ZIP archive of the given size read from r into dest.
+// It is the ZIP counterpart of ExtractTarGz and applies the same protections: entries with
+// absolute paths, entries leaving 'dest', links pointing outside of 'dest' and entries written
+// through an existing symlink leading outside of 'dest' are rejected with an *UnsafeEntryError.
+// Members are streamed to disk, ZIP64 archives are supported.
+func ExtractZip(r io.ReaderAt, size int64, dest string) error {
+	return ExtractZipWithOptions(r, size, dest, ExtractOptions{})
+}
+
+// ExtractZipWithOptions works like ExtractZip, but lets opts control how entries are written and
+// enforces the limits of opts, like ExtractTarGzWithOptions. The compression ratio is computed
+// from the compressed sizes of the entries extracted so far.
+func ExtractZipWithOptions(r io.ReaderAt, size int64, dest string, opts ExtractOptions) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil && err != zip.ErrInsecurePath {
+		return err
+	}
+
+	var compressed int64
+	guard := &extractGuard{opts: opts, compressed: func() int64 { return compressed }}
+
+	x, err := newExtractor(dest, opts, guard)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		compressed += int64(f.CompressedSize64)
+		if err := extractZipEntry(x, f); err != nil {
+			return err
+		}
+	}
+
+	return x.finish()
+}
+
+// extractZipEntry extracts a single ZIP member
+func extractZipEntry(x *extractor, f *zip.File) error {
+	entry := zipEntry(&f.FileHeader)
+	if entry.Type == EntryDir {
+		return x.extract(entry, nil)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func(rc io.ReadCloser) {
+		_ = rc.Close()
+	}(rc)
+
+	if entry.Type == EntrySymlink {
+		// The target of a symlink is stored as the content of the entry
+		link, err := io.ReadAll(io.LimitReader(rc, maxZipLinkSize+1))
+		if err != nil {
+			return err
+		}
+		if len(link) > maxZipLinkSize {
+			return fmt.Errorf("symlink target of %s is too long", f.Name)
+		}
+		entry.Linkname = string(link)
+		return x.extract(entry, nil)
+	}
+
+	return x.extract(entry, rc)
+}
+
+// zipEntry converts a ZIP file header into an ArchiveEntry
+func zipEntry(header *zip.FileHeader) ArchiveEntry {
+	mode := header.Mode()
+	entry := ArchiveEntry{
+		Name:    header.Name,
+		Size:    int64(header.UncompressedSize64),
+		Mode:    mode,
+		ModTime: header.Modified,
+	}
+
+	switch {
+	case mode.IsDir() || strings.HasSuffix(header.Name, "/"):
+		entry.Type = EntryDir
+	case mode&os.ModeSymlink != 0:
+		entry.Type = EntrySymlink
+	case mode.IsRegular():
+		entry.Type = EntryFile
+	default:
+		entry.Type = EntryOther
+	}
+
+	return entry
+}
+
+// CreateZip creates a ZIP archive at zipPath from the source directory, like CreateTarGz does for tar.gz.
+// Names inside the archive are relative to relPath, or to sourcePath if relPath is empty.
+// All entries are deflated and the source files are kept.
+func CreateZip(zipPath, sourcePath, relPath string) error {
+	return CreateZipWithOptions(zipPath, sourcePath, relPath, ArchiveOptions{})
+}
+
+// CreateZipWithOptions works like CreateTarGzWithOptions, but writes a ZIP archive. The compression
+// method of every entry can be chosen with opts.ZipMethod, e.g. to store already compressed files.
+// File content is streamed, and ZIP64 records are written automatically for large archives.
+func CreateZipWithOptions(zipPath, sourcePath, relPath string, opts ArchiveOptions) error {
+	files, err := collectArchiveFiles(sourcePath, relPath, zipPath, opts)
+	if err != nil {
+		return err
+	}
+
+	// The archive only appears once it is complete
+	zipFile, err := NewAtomicWriter(zipPath, 0644)
+	if err != nil {
+		return err
+	}
+	defer zipFile.Abort()
+
+	if err := writeZip(zipFile, files, opts); err != nil {
+		return err
+	}
+	if err := zipFile.Close(); err != nil {
+		return err
+	}
+
+	if !opts.RemoveSources {
+		return nil
+	}
+
+	// A damaged archive is removed, so it is not mistaken for a valid one
+	if err := verifyZipEntries(zipPath, archiveEntryCount(files, opts)); err != nil {
+		_ = os.Remove(zipPath)
+		return err
+	}
+
+	return removeArchivedFiles(files, opts.Trash)
+}
+
+// writeZip writes the files as ZIP archive to w
+func writeZip(w io.Writer, files []archiveFile, opts ArchiveOptions) error {
+	manifest, err := newManifestWriter(files, opts)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	for _, file := range files {
+		h := manifest.hash()
+		if err := writeZipEntry(zw, file, opts, h); err != nil {
+			return err
+		}
+		if file.info.Mode().IsRegular() {
+			manifest.add(file.name, h)
+		}
+	}
+
+	if manifest != nil {
+		header := &zip.FileHeader{
+			Name:     opts.Manifest.ManifestName(),
+			Method:   zip.Deflate,
+			Modified: manifestModTime(files, opts).UTC(),
+		}
+		header.SetMode(0644)
+		mw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := mw.Write(manifest.content()); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// writeZipEntry writes the header and the streamed content of file.
+// If h is not nil, the content of regular files is hashed as well.
+func writeZipEntry(zw *zip.Writer, file archiveFile, opts ArchiveOptions, h hash.Hash) error {
+	header, err := zip.FileInfoHeader(file.info)
+	if err != nil {
+		return err
+	}
+	header.Name = file.name
+
+	switch {
+	case file.info.IsDir():
+		header.Method = zip.Store
+	case opts.ZipMethod != nil:
+		header.Method = opts.ZipMethod(file.path, file.info)
+	default:
+		header.Method = zip.Deflate
+	}
+
+	if opts.Reproducible {
+		header.Modified = header.Modified.Truncate(time.Second)
+		if !opts.ModTime.IsZero() {
+			header.Modified = opts.ModTime.Truncate(time.Second)
+		}
+		header.Modified = header.Modified.UTC()
+	}
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if file.info.IsDir() {
+		return nil
+	}
+	if file.linkname != "" {
+		_, err := io.WriteString(w, file.linkname)
+		return err
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	if h != nil {
+		w = io.MultiWriter(w, h)
+	}
+
+	n, err := io.CopyN(w, f, file.info.Size())
+	if err != nil {
+		return fmt.Errorf("failed to archive %s (%d of %d bytes written): %w", file.path, n, file.info.Size(), err)
+	}
+	return nil
+}
+
+// verifyZipEntries reads every entry of the archive, which checks the CRCs, and compares the number of entries
+func verifyZipEntries(path string, want int) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer func(zr *zip.ReadCloser) {
+		_ = zr.Close()
+	}(zr)
+
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("verification of %s failed: %w", path, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		_ = rc.Close()
+		if err != nil {
+			return fmt.Errorf("verification of %s failed: %w", path, err)
+		}
+	}
+
+	if len(zr.File) != want {
+		return fmt.Errorf("verification of %s failed: %d entries found, %d expected", path, len(zr.File), want)
+	}
+	return nil
+}
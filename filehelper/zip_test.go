@@ -0,0 +1,118 @@
+package filehelper
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withUmask sets the umask of the process for the test and restores it afterwards
+func withUmask(t *testing.T, mask os.FileMode) {
+	t.Helper()
+	old := unix.Umask(int(mask))
+	umask = mask
+	t.Cleanup(func() {
+		unix.Umask(old)
+		umask = os.FileMode(old)
+	})
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	for path, content := range map[string]string{"a.csv": "a", "sub/b.gz": "b"} {
+		path = filepath.Join(src, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.csv", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "out.zip")
+	opts := ArchiveOptions{ZipMethod: func(path string, info os.FileInfo) uint16 {
+		if filepath.Ext(path) == ".gz" {
+			return zip.Store
+		}
+		return zip.Deflate
+	}}
+	if err := CreateZipWithOptions(archive, src, "", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if err := ExtractZipFile(archive, dest); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{"a.csv": "a", "sub/b.gz": "b", "link": "a"} {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(path)))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, want %q : %v", path, got, want, err)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "link")); err != nil || target != "a.csv" {
+		t.Errorf("got link target %q : %v", target, err)
+	}
+}
+
+func TestExtractZipUnsafe(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("../evil.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ExtractZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), t.TempDir())
+	if !errors.Is(err, ErrPathTraversal) {
+		t.Fatalf("got %v, want %v", err, ErrPathTraversal)
+	}
+}
+
+func TestExtractZipMasksModes(t *testing.T) {
+	withUmask(t, 027)
+
+	// Zip files created on Windows or FAT file systems report 0666 for files and 0777 for directories
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, hdr := range []*zip.FileHeader{
+		{Name: "dir/", CreatorVersion: 0, ExternalAttrs: 0x10}, // MS-DOS directory attribute
+		{Name: "dir/a.txt", CreatorVersion: 0},
+	} {
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if err := ExtractZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), dest); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]os.FileMode{"dir": 0750, "dir/a.txt": 0640} {
+		info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(path)))
+		if err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s: got permissions %v : %v, want %v", path, info.Mode().Perm(), err, want)
+		}
+	}
+}
+
+func TestExtractAllZipInDirectoryNilLogger(t *testing.T) {
+	// A directory which cannot be read is reported to the logger, which may be nil
+	ExtractAllZipInDirectory(filepath.Join(t.TempDir(), "missing"), t.TempDir(), nil)
+}